@@ -0,0 +1,22 @@
+package limiter
+
+//AcquireOption changes how a single request is acquired by CanMakeRequest, WaitForRatelimit or MultiAcquire.
+//RequestSuccessful and RequestCancelled only use WithGrant.
+type AcquireOption func(*acquireOptions)
+
+//acquireOptions holds everything the AcquireOptions of a request can change.
+type acquireOptions struct {
+	priority       Priority
+	consumer       string //empty if the request does not take part in fair sharing
+	consumerWeight int
+	grant          *Grant //filled in with how the request was granted, nil if the caller does not track it
+}
+
+func newAcquireOptions(opts []AcquireOption) acquireOptions {
+	options := acquireOptions{priority: PriorityNormal}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	return options
+}
@@ -0,0 +1,128 @@
+package limiter
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mediocregopher/radix/v3"
+)
+
+//defaultBanPolicy is used by configs that were not given a BanPolicy.
+var defaultBanPolicy = BanPolicy{Cooldown: time.Minute, MaxCooldown: time.Hour, Reduction: 0.5}
+
+//BanPolicy controls how a Limiter reacts to being banned by an api, which is usually reported
+//with a 419 status code instead of the 429 of a normal throttle.
+type BanPolicy struct {
+	//Cooldown is how long no requests are approved after the first ban. It doubles with every
+	//ban that follows before the previous cooldown has passed twice over.
+	Cooldown time.Duration
+	//MaxCooldown caps the escalating cooldown. 0 does not cap it.
+	MaxCooldown time.Duration
+	//Reduction is the share of the request limit removed by a ban, between 0 and 1.
+	//At least the weight of the banned request is always removed.
+	Reduction float64
+}
+
+//SetBanPolicy sets how HitBan reacts to a ban. Configs without a BanPolicy cool down for
+//one minute after the first ban, up to one hour, and halve their request limit.
+//
+//	config.SetBanPolicy(BanPolicy{Cooldown: 5 * time.Minute, MaxCooldown: time.Hour, Reduction: 0.25})
+func (rl *RateLimitConfig) SetBanPolicy(policy BanPolicy) {
+	rl.banPolicy = policy
+}
+
+//getBanPolicy returns the BanPolicy of the config, or the default one if it was not set.
+func (rl RateLimitConfig) getBanPolicy() BanPolicy {
+	if rl.banPolicy == (BanPolicy{}) {
+		return defaultBanPolicy
+	}
+
+	return rl.banPolicy
+}
+
+//validate returns an error if the BanPolicy is not possible.
+func (p BanPolicy) validate() error {
+	if p.Cooldown < 0 || p.MaxCooldown < 0 {
+		return fmt.Errorf("ban cooldowns must not be negative, got %v and %v", p.Cooldown, p.MaxCooldown)
+	}
+
+	if p.Reduction < 0 || p.Reduction > 1 {
+		return fmt.Errorf("ban reduction must be between 0 and 1, got %v", p.Reduction)
+	}
+
+	return nil
+}
+
+//cooldown returns how long the given ban in a row lasts
+func (p BanPolicy) cooldown(ban int) time.Duration {
+	cooldown := p.Cooldown
+	for i := 1; i < ban; i++ {
+		if p.MaxCooldown > 0 && cooldown >= p.MaxCooldown {
+			break
+		}
+		cooldown *= 2
+	}
+
+	if p.MaxCooldown > 0 && cooldown > p.MaxCooldown {
+		return p.MaxCooldown
+	}
+
+	return cooldown
+}
+
+//HitBan must be called only after CanMakeRequest returned true and a request has been
+//completed with a status code of 419, or any other response that means the api banned
+//the client for making too many requests. Unlike HitRateLimit, no requests are approved
+//for the cooldown of the BanPolicy and the request limit is cut by its Reduction.
+//
+//Bans that follow each other closely escalate the cooldown. A ban reported while the host is still
+//banned, such as by the other requests that were in flight when it started, is the same ban, so it
+//neither escalates the cooldown nor cuts the request limit again. The number of bans in a row and
+//the end of the current ban are kept in the status of the host, so every process respects them.
+//If the Limiter has a parent, the level that has used the largest share of its request limit is banned.
+func (l *Limiter) HitBan(requestWeight int) error {
+	return l.hitLimit(requestWeight, func(limited *Limiter, c radix.Conn) error {
+		return limited.ban(requestWeight, c)
+	})
+}
+
+//ban reduces the request limit by the Reduction of the BanPolicy and saves the escalated ban to
+//the status of the host. It must be called inside of a transaction, after the status was loaded.
+func (l *Limiter) ban(requestWeight int, c radix.Conn) error {
+	policy := l.config.getBanPolicy()
+	now := getUnixTimeMilliseconds()
+
+	//the host is still banned, so the request only finishes
+	if now < l.status.banUntil {
+		return nil
+	}
+
+	count := l.status.bans
+	//a ban long after the previous one ended starts over at the first cooldown
+	if count > 0 && now-l.status.banUntil > durationToMilliseconds(policy.cooldown(count)) {
+		count = 0
+	}
+	count++
+
+	reduction := int(float64(l.config.requestLimit) * policy.Reduction)
+	if reduction < requestWeight {
+		reduction = requestWeight
+	}
+
+	//even the steepest reduction leaves one request, since a limit of 0 is an infinite rate
+	if reduction >= l.config.requestLimit {
+		reduction = l.config.requestLimit - 1
+	}
+
+	if err := l.adjustConfig(reduction, c); err != nil {
+		return err
+	}
+
+	l.status.bans = count
+	l.status.banUntil = now + durationToMilliseconds(policy.cooldown(count))
+
+	return c.Do(radix.FlatCmd(nil, "HSET", l.getStatusKey(),
+		bans, l.status.bans,
+		banUntil, l.status.banUntil,
+	))
+}
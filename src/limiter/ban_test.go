@@ -0,0 +1,117 @@
+package limiter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mediocregopher/radix/v3"
+)
+
+func Test_BanCooldown(t *testing.T) {
+	policy := BanPolicy{Cooldown: time.Minute, MaxCooldown: 10 * time.Minute, Reduction: 0.5}
+
+	type TestBanCooldown struct {
+		ban      int
+		expected time.Duration
+	}
+
+	testCases := []TestBanCooldown{
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{4, 8 * time.Minute},
+		{5, 10 * time.Minute},
+		{100, 10 * time.Minute},
+	}
+
+	for i := 0; i < len(testCases); i++ {
+		if cooldown := policy.cooldown(testCases[i].ban); cooldown != testCases[i].expected {
+			t.Errorf("Loop: %v. Expected %v, got: %v", i, testCases[i].expected, cooldown)
+		}
+	}
+}
+
+func Test_InvalidBanPolicy(t *testing.T) {
+	config := NewRateLimitConfig("banHost", 10, 1, 10, 1, 0)
+	config.SetBanPolicy(BanPolicy{Cooldown: time.Minute, Reduction: 1.5})
+
+	if err := config.Validate(); err == nil {
+		t.Errorf("Expected an error for a ban reduction larger than 1")
+	}
+}
+
+func Test_HitBan(t *testing.T) {
+	deleteTestHosts(t, "banHost")
+
+	config := newTestConfig("banHost", 10)
+	config.SetBanPolicy(BanPolicy{Cooldown: 10 * time.Second, Reduction: 0.5})
+
+	limiter, err := NewLimiter(config, pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type TestHitBan struct {
+		ended    bool //the previous ban ended a moment ago
+		limit    int
+		bans     int
+		cooldown time.Duration
+	}
+
+	testCases := []TestHitBan{
+		{false, 5, 1, 10 * time.Second},
+		{false, 5, 1, 10 * time.Second}, //reported by another request during the first ban, so it is the same ban
+		{true, 3, 2, 20 * time.Second},  //a ban right after the first one escalates the cooldown
+	}
+
+	for i := 0; i < len(testCases); i++ {
+		if testCases[i].ended {
+			if err := pool.Do(radix.FlatCmd(nil, "HSET", "status:banHost", banUntil, getUnixTimeMilliseconds()-1000)); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		start := getUnixTimeMilliseconds()
+
+		if err := limiter.HitBan(1); err != nil {
+			t.Fatal(err)
+		}
+
+		canMake, wait, err := limiter.CanMakeRequest(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if canMake || wait < testCases[i].cooldown-time.Second || wait > testCases[i].cooldown {
+			t.Errorf("Loop: %v. Expected to wait about %v, got: %v, %v", i, testCases[i].cooldown, canMake, wait)
+		}
+
+		status := limiter.GetStatus()
+		if status.bans != testCases[i].bans || status.banUntil < start+durationToMilliseconds(testCases[i].cooldown-time.Second) {
+			t.Errorf("Loop: %v. Expected ban %v to last %v, got: %v", i, testCases[i].bans, testCases[i].cooldown, status)
+		}
+
+		if limiter.config.requestLimit != testCases[i].limit {
+			t.Errorf("Loop: %v. Expected the limit to be cut to %v, got: %v", i, testCases[i].limit, limiter.config.requestLimit)
+		}
+	}
+}
+
+func Test_HitBanFullReduction(t *testing.T) {
+	deleteTestHosts(t, "banHost2")
+
+	config := newTestConfig("banHost2", 10)
+	config.SetBanPolicy(BanPolicy{Cooldown: 10 * time.Second, Reduction: 1})
+
+	limiter, err := NewLimiter(config, pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := limiter.HitBan(1); err != nil {
+		t.Fatal(err)
+	}
+
+	if limiter.config.requestLimit != 1 {
+		t.Errorf("Expected the steepest reduction to cut the limit to 1, got: %v", limiter.config.requestLimit)
+	}
+}
@@ -0,0 +1,69 @@
+package limiter
+
+import (
+	"context"
+	"time"
+)
+
+//AcquireN reserves as many as it can of count requests with the given request weight, in a single
+//transaction. It returns how many requests were granted and, if that is less than count, how long
+//to wait before the rest can be acquired. Requests are only granted while every level of the limiter
+//allows them, following the same rules as CanMakeRequest.
+//
+//The wait for the rest counts the periods and the time between requests every level needs to fit them,
+//assuming the pending requests finish. Fair sharing, recovery probes and other processes are not
+//foreseen, so the rest can still be partly denied after the wait.
+//
+//	granted, wait, err := limiter.AcquireN(ctx, 500, 1)
+//RequestSuccessful, HitRateLimit or RequestCancelled must be called for every granted request.
+//AcquireN returns the error of the context if it is done before the requests are reserved.
+//It does not use the lease of a Limiter created WithLease.
+func (l *Limiter) AcquireN(ctx context.Context, count int, requestWeight int, opts ...AcquireOption) (int, time.Duration, error) {
+	if count <= 0 {
+		return 0, 0, nil
+	}
+
+	return reserve(ctx, l.pool, l.levels(), l.levelWeights(requestWeight), count, newAcquireOptions(opts))
+}
+
+//restWait returns how long in milliseconds after now the limiter can grant count more requests of the weight,
+//by playing its decisions forward in time from the loaded status. The limiter must be locked.
+func (l *Limiter) restWait(requestWeight int, count int, options acquireOptions, now int64) int64 {
+	decayed := l.config.decayed(now)
+	config := decayed.warmedUp(l.status, now).forPriority(options.priority)
+	if config.requestLimit < requestWeight {
+		//a request larger than the warmed up limit fits once the warm-up is over
+		config = decayed.forPriority(options.priority)
+	}
+
+	//an infinite rate fits every request, and a request larger than the limit never fits
+	if config.requestLimit == 0 || config.requestLimit < requestWeight {
+		return 0
+	}
+
+	//the pending requests are counted as finished in their period, and the host as recovered
+	status := l.status
+	status.requests += status.pendingRequests
+	status.pendingRequests = 0
+	status.probes = 0
+	status.probing = 0
+
+	at := now
+	//every request is granted at the latest after one wait, so the loop always ends
+	for granted := 0; granted < count; {
+		canMake, wait := status.canMakeRequestAt(requestWeight, config, at)
+		if canMake {
+			status.requests += status.pendingRequests
+			status.pendingRequests = 0
+			granted++
+			continue
+		}
+
+		if wait < 1 {
+			wait = 1
+		}
+		at += wait
+	}
+
+	return at - now
+}
@@ -0,0 +1,120 @@
+package limiter
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func Test_AcquireN(t *testing.T) {
+	deleteTestHosts(t, "batchAccount", "batchImports")
+
+	account, err := NewLimiter(newTestConfig("batchAccount", 10), pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	imports, err := NewLimiter(newTestConfig("batchImports", 8), pool, WithParent(account))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type TestAcquireN struct {
+		limiter       *Limiter
+		count         int
+		requestWeight int
+		granted       int
+		waits         bool
+	}
+
+	testCases := []TestAcquireN{
+		{imports, 3, 2, 3, false},
+		{imports, 5, 1, 2, true},  //the limit of imports is reached
+		{account, 5, 1, 2, true},  //the account still has the 2 imports did not use
+		{imports, 0, 1, 0, false}, //nothing is asked for
+	}
+
+	for i := 0; i < len(testCases); i++ {
+		granted, wait, err := testCases[i].limiter.AcquireN(context.Background(), testCases[i].count, testCases[i].requestWeight)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if granted != testCases[i].granted {
+			t.Errorf("Loop: %v. Expected %v requests to be granted, got: %v", i, testCases[i].granted, granted)
+		}
+
+		if (wait > 0) != testCases[i].waits {
+			t.Errorf("Loop: %v. Expected a wait time: %v, got: %v", i, testCases[i].waits, wait)
+		}
+	}
+
+	type expectedStatus struct {
+		host    string
+		pending int
+	}
+
+	for _, expected := range []expectedStatus{{"batchImports", 8}, {"batchAccount", 10}} {
+		if pending := getPendingRequests(t, expected.host); pending != expected.pending {
+			t.Errorf("Expected %v pending requests for %v, got: %v", expected.pending, expected.host, pending)
+		}
+	}
+}
+
+func Test_AcquireNRestWait(t *testing.T) {
+	deleteTestHosts(t, "batchRest", "batchRestSpaced")
+
+	burst, err := NewLimiter(newTestConfig("batchRest", 10), pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spacedConfig := newTestConfig("batchRestSpaced", 10)
+	spacedConfig.SetBurstMode(false)
+	spaced, err := NewLimiter(spacedConfig, pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type TestRestWait struct {
+		limiter *Limiter
+		count   int
+		granted int
+		minWait time.Duration
+		maxWait time.Duration
+	}
+
+	testCases := []TestRestWait{
+		{burst, 25, 10, time.Second + 900*time.Millisecond, 2 * time.Second}, //the rest of 15 needs two more periods
+		{spaced, 5, 1, 350 * time.Millisecond, 400 * time.Millisecond},       //the rest of 4 needs 4 gaps of 100ms
+	}
+
+	for i := 0; i < len(testCases); i++ {
+		granted, wait, err := testCases[i].limiter.AcquireN(context.Background(), testCases[i].count, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if granted != testCases[i].granted {
+			t.Errorf("Loop: %v. Expected %v requests to be granted, got: %v", i, testCases[i].granted, granted)
+		}
+
+		if wait < testCases[i].minWait || wait > testCases[i].maxWait {
+			t.Errorf("Loop: %v. Expected to wait between %v and %v for the rest, got: %v", i, testCases[i].minWait, testCases[i].maxWait, wait)
+		}
+	}
+}
+
+func Test_AcquireNCancelledContext(t *testing.T) {
+	limiter, err := NewLimiter(newTestConfig("batchCancelled", 10), pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, _, err := limiter.AcquireN(ctx, 5, 1); err != context.Canceled {
+		t.Errorf("Expected the error of the context, got: %v", err)
+	}
+}
@@ -0,0 +1,137 @@
+package limiter
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/mediocregopher/radix/v3"
+)
+
+//SetLimitBounds keeps the adjustments of HitRateLimit and HitBan between a lowest and a highest rate,
+//so a burst of 429s caused by someone else cannot throttle the host down to one request per period.
+//A zero Window leaves that side unbounded.
+//
+//	config.SetLimitBounds(Window{Limit: 5, Period: time.Second}, Window{})
+//The bounds are converted to the time period of the config, so SetLimitBounds must be called on a
+//config that already has its rate limits.
+func (rl *RateLimitConfig) SetLimitBounds(min Window, max Window) {
+	rl.minLimit = int(math.Ceil(rl.limitPerPeriod(min)))
+	rl.maxLimit = int(math.Floor(rl.limitPerPeriod(max)))
+}
+
+//limitPerPeriod converts the rate of the window to a number of requests per time period of the config
+func (rl RateLimitConfig) limitPerPeriod(w Window) float64 {
+	period := durationToMilliseconds(w.Period)
+	if w.Limit <= 0 || period <= 0 || rl.timePeriod == 0 {
+		return 0
+	}
+
+	return float64(w.Limit) * float64(rl.timePeriod) / float64(period)
+}
+
+//validateLimitBounds returns an error if the bounds are not possible or the request limit is outside of them.
+func (rl RateLimitConfig) validateLimitBounds() error {
+	if rl.minLimit < 0 || rl.maxLimit < 0 {
+		return fmt.Errorf("limit bounds must not be negative, got %v and %v", rl.minLimit, rl.maxLimit)
+	}
+
+	if rl.maxLimit > 0 && rl.minLimit > rl.maxLimit {
+		return fmt.Errorf("lowest limit %v is higher than the highest limit %v", rl.minLimit, rl.maxLimit)
+	}
+
+	//an infinite rate has no bounds
+	if rl.requestLimit == 0 {
+		return nil
+	}
+
+	if rl.requestLimit < rl.minLimit || (rl.maxLimit > 0 && rl.requestLimit > rl.maxLimit) {
+		return fmt.Errorf("limit %v is outside of the bounds %v to %v", rl.requestLimit, rl.minLimit, rl.maxLimit)
+	}
+
+	return nil
+}
+
+//boundLimit returns the request limit kept within the bounds of the config
+func (rl RateLimitConfig) boundLimit(limit int) int {
+	if limit < rl.minLimit {
+		return rl.minLimit
+	}
+
+	if rl.maxLimit > 0 && limit > rl.maxLimit {
+		return rl.maxLimit
+	}
+
+	return limit
+}
+
+//ResetConfig restores the request limit the host was configured with, the one saved by the last UpdateConfig,
+//import or snapshot of any process, undoing every adjustment made since. Every limiter using the host sees the
+//change within one decision. Hosts saved without that limit are restored to the config the limiter was created with.
+func (l *Limiter) ResetConfig() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for {
+		aborted, err := l.tryResetConfig()
+		if err != nil || !aborted {
+			return err
+		}
+	}
+}
+
+//tryResetConfig makes one attempt at restoring the base limit of the host. aborted is true when
+//another process changed the config before it was saved. The limiter must be locked.
+func (l *Limiter) tryResetConfig() (bool, error) {
+	key := l.getConfigKey()
+	var resp []string
+
+	err := l.pool.Do(radix.WithConn(key, func(c radix.Conn) error {
+		if err := c.Do(radix.Cmd(nil, "WATCH", key)); err != nil {
+			return err
+		}
+
+		config := l.base
+		if err := config.updateConfigFromDatabase(c, key); err != nil {
+			c.Do(radix.Cmd(nil, "UNWATCH"))
+			return err
+		}
+
+		if config.baseLimit == 0 {
+			config.baseLimit = l.base.requestLimit
+		}
+		config.requestLimit = config.baseLimit
+		config.reducedAt = 0
+		config.stableLimit = 0
+		config.raisedAt = 0
+		config.settled = false
+		config.setTimeBetweenRequests()
+
+		if err := c.Do(radix.Cmd(nil, "MULTI")); err != nil {
+			return err
+		}
+
+		var err error
+		defer func() {
+			if err != nil {
+				c.Do(radix.Cmd(nil, "DISCARD"))
+			}
+		}()
+
+		l.config = config
+		if err = l.saveConfig(c); err != nil {
+			return err
+		}
+
+		if err = l.publish(c, eventConfig); err != nil {
+			return err
+		}
+
+		return c.Do(radix.Cmd(&resp, "EXEC"))
+	}))
+	if err != nil {
+		return false, err
+	}
+
+	//resp is nil if the transaction was aborted
+	return resp == nil, nil
+}
@@ -0,0 +1,153 @@
+package limiter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mediocregopher/radix/v3"
+)
+
+func Test_SetLimitBounds(t *testing.T) {
+	type TestLimitBounds struct {
+		config      RateLimitConfig
+		min         Window
+		max         Window
+		expectedMin int
+		expectedMax int
+	}
+
+	testCases := []TestLimitBounds{
+		{NewRateLimitConfig("test_host_1", 20, 1, 20, 1, 0), Window{5, time.Second}, Window{40, time.Second}, 5, 40},
+		{NewRateLimitConfig("test_host_1", 20, 1, 20, 1, 0), Window{30, time.Minute}, Window{}, 1, 0},
+		{NewRateLimitConfig("test_host_1", 20, 1, 20, 1, 0), Window{}, Window{90, time.Minute}, 0, 1},
+		{NewRateLimitConfig("test_host_1", 0, 0, 0, 0, 0), Window{5, time.Second}, Window{40, time.Second}, 0, 0},
+	}
+
+	for i := 0; i < len(testCases); i++ {
+		config := testCases[i].config
+		config.SetLimitBounds(testCases[i].min, testCases[i].max)
+
+		if config.minLimit != testCases[i].expectedMin || config.maxLimit != testCases[i].expectedMax {
+			t.Errorf("Loop: %v. Expected %v to %v, got: %v to %v", i, testCases[i].expectedMin,
+				testCases[i].expectedMax, config.minLimit, config.maxLimit)
+		}
+	}
+
+	config := NewRateLimitConfig("test_host_1", 20, 1, 20, 1, 0)
+	config.SetLimitBounds(Window{30, time.Second}, Window{})
+	if err := config.Validate(); err == nil {
+		t.Errorf("Expected an error for a limit below its lowest bound")
+	}
+}
+
+func Test_LimitBoundsAndResetConfig(t *testing.T) {
+	deleteTestHosts(t, "boundsHost")
+
+	config := newTestConfig("boundsHost", 10)
+	config.SetLimitBounds(Window{7, time.Second}, Window{})
+
+	limiter, err := NewLimiter(config, pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 5; i++ {
+		if err := limiter.HitRateLimit(1); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if limiter.config.requestLimit != 7 {
+		t.Errorf("Expected repeated 429s to stop at the lowest limit of 7, got: %v", limiter.config.requestLimit)
+	}
+
+	if err := limiter.HitBan(1); err != nil {
+		t.Fatal(err)
+	}
+
+	if limiter.config.requestLimit != 7 {
+		t.Errorf("Expected a ban to stop at the lowest limit of 7, got: %v", limiter.config.requestLimit)
+	}
+
+	if err := limiter.ResetConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	var restored int
+	if err := pool.Do(radix.Cmd(&restored, "HGET", "config:boundsHost", limit)); err != nil {
+		t.Fatal(err)
+	}
+
+	if restored != 10 {
+		t.Errorf("Expected ResetConfig to restore the limit of 10, got: %v", restored)
+	}
+}
+
+func Test_ResetConfigToSharedBase(t *testing.T) {
+	deleteTestHosts(t, "boundsHost3")
+
+	first, err := NewLimiter(newTestConfig("boundsHost3", 10), pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := NewLimiter(newTestConfig("boundsHost3", 10), pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := first.UpdateConfig(newTestConfig("boundsHost3", 6)); err != nil {
+		t.Fatal(err)
+	}
+
+	//the other process loads the updated config
+	if _, _, err := second.CanMakeRequest(1); err != nil {
+		t.Fatal(err)
+	}
+
+	type TestSharedBase struct {
+		adjust func() error
+		limit  int
+	}
+
+	testCases := []TestSharedBase{
+		{func() error { return second.HitRateLimit(1) }, 5}, //a 429 in another process keeps the updated base
+		{second.ResetConfig, 6},
+	}
+
+	for i := 0; i < len(testCases); i++ {
+		if err := testCases[i].adjust(); err != nil {
+			t.Fatal(err)
+		}
+
+		var values []int
+		if err := pool.Do(radix.Cmd(&values, "HMGET", "config:boundsHost3", limit, baseLimit)); err != nil {
+			t.Fatal(err)
+		}
+
+		if values[0] != testCases[i].limit || values[1] != 6 {
+			t.Errorf("Loop: %v. Expected a limit of %v from the base of 6, got: %v", i, testCases[i].limit, values)
+		}
+	}
+}
+
+func Test_LargeReductionStopsAtLowestBound(t *testing.T) {
+	deleteTestHosts(t, "boundsHost2")
+
+	config := newTestConfig("boundsHost2", 10)
+	config.SetLimitBounds(Window{7, time.Second}, Window{})
+
+	limiter, err := NewLimiter(config, pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//a reduction larger than the whole limit
+	if err := limiter.HitRateLimit(15); err != nil {
+		t.Fatal(err)
+	}
+
+	if limiter.config.requestLimit != 7 {
+		t.Errorf("Expected a large reduction to stop at the lowest limit of 7, got: %v", limiter.config.requestLimit)
+	}
+}
@@ -0,0 +1,43 @@
+package limiter
+
+import "time"
+
+//SetReductionDecay makes the reductions of HitRateLimit and HitBan wear off over the given time.
+//The limit drifts back linearly from the reduced limit to the limit the host was configured with,
+//which it reaches once the time has passed since the last reduction. The default of 0 keeps
+//reductions until the config is reset or updated.
+//
+//	config.SetReductionDecay(time.Hour)
+func (rl *RateLimitConfig) SetReductionDecay(ttl time.Duration) {
+	if ttl < 0 {
+		ttl = 0
+	}
+
+	rl.reductionDecay = durationToMilliseconds(ttl)
+}
+
+//decayed returns the config with the part of the last reduction that has worn off at the given time
+//added back to the request limit.
+func (rl RateLimitConfig) decayed(now int64) RateLimitConfig {
+	if rl.reductionDecay <= 0 || rl.reducedAt <= 0 || rl.baseLimit <= rl.requestLimit {
+		return rl
+	}
+
+	progress := float64(now-rl.reducedAt) / float64(rl.reductionDecay)
+	if progress <= 0 {
+		return rl
+	}
+
+	if progress > 1 {
+		progress = 1
+	}
+
+	recovered := int(float64(rl.baseLimit-rl.requestLimit) * progress)
+	if recovered == 0 {
+		return rl
+	}
+
+	rl.requestLimit = rl.boundLimit(rl.requestLimit + recovered)
+	rl.setTimeBetweenRequests()
+	return rl
+}
@@ -0,0 +1,119 @@
+package limiter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mediocregopher/radix/v3"
+)
+
+func Test_Decayed(t *testing.T) {
+	now := getUnixTimeMilliseconds()
+
+	reduced := func(requestLimit int, base int, at int64) RateLimitConfig {
+		config := NewRateLimitConfig("test_host_1", 100, 1, 100, 1, 0)
+		config.SetReductionDecay(10 * time.Second)
+		config.requestLimit = requestLimit
+		config.baseLimit = base
+		config.reducedAt = at
+		return config
+	}
+
+	type TestDecayed struct {
+		config   RateLimitConfig
+		expected int
+	}
+
+	testCases := []TestDecayed{
+		{reduced(40, 100, now), 40},
+		{reduced(40, 100, now-5000), 70},
+		{reduced(40, 100, now-10000), 100},
+		{reduced(40, 100, now-60000), 100},
+		{reduced(40, 100, 0), 40},         //never reduced
+		{reduced(40, 0, now-10000), 40},   //base limit not known
+		{reduced(100, 60, now-5000), 100}, //limit above the base is not lowered
+	}
+
+	for i := 0; i < len(testCases); i++ {
+		config := testCases[i].config.decayed(now)
+		if config.requestLimit != testCases[i].expected {
+			t.Errorf("Loop: %v. Expected %v, got: %v", i, testCases[i].expected, config.requestLimit)
+		}
+	}
+
+	noDecay := reduced(40, 100, now-10000)
+	noDecay.SetReductionDecay(0)
+	if config := noDecay.decayed(now); config.requestLimit != 40 {
+		t.Errorf("Expected reductions to be kept without a decay, got: %v", config.requestLimit)
+	}
+}
+
+func Test_ReductionDecay(t *testing.T) {
+	deleteTestHosts(t, "decayHost")
+
+	config := newTestConfig("decayHost", 10)
+	config.SetReductionDecay(10 * time.Second)
+
+	limiter, err := NewLimiter(config, pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 4; i++ {
+		if err := limiter.HitRateLimit(1); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	//the reductions were made half of the decay ago, in an earlier period
+	err = pool.Do(radix.Pipeline(
+		radix.FlatCmd(nil, "HSET", "status:decayHost", requests, 0, pendingRequests, 0, firstRequest, 0),
+		radix.FlatCmd(nil, "HSET", "config:decayHost", reducedAt, getUnixTimeMilliseconds()-5000),
+		radix.FlatCmd(nil, "HINCRBY", "config:decayHost", configVersion, 1),
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	granted, _, err := limiter.AcquireN(context.Background(), 10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if granted != 8 {
+		t.Errorf("Expected half of the reduction from 10 to 6 to have worn off, got %v requests", granted)
+	}
+}
+
+func Test_ReductionDecayAllowsHeavierRequests(t *testing.T) {
+	deleteTestHosts(t, "decayHost2")
+
+	config := newTestConfig("decayHost2", 10)
+	config.SetReductionDecay(10 * time.Second)
+
+	limiter, err := NewLimiter(config, pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 4; i++ {
+		if err := limiter.HitRateLimit(1); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	//the cached limit is 6, but half of the reduction has worn off since
+	err = pool.Do(radix.Pipeline(
+		radix.FlatCmd(nil, "HSET", "status:decayHost2", requests, 0, pendingRequests, 0, firstRequest, 0),
+		radix.FlatCmd(nil, "HSET", "config:decayHost2", reducedAt, getUnixTimeMilliseconds()-5000),
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	canMake, _, err := limiter.CanMakeRequest(7)
+	if err != nil || !canMake {
+		t.Errorf("Expected a request of weight 7 to fit the decayed limit of 8, got: %v, %v", canMake, err)
+	}
+}
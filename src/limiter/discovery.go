@@ -0,0 +1,169 @@
+package limiter
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mediocregopher/radix/v3"
+)
+
+//defaultDiscoveryMax is how far above the configured limit discovery goes when Max is not set.
+const defaultDiscoveryMax = 2
+
+//Discovery raises the request limit of a host above the published rate, in steps, to find the
+//real rate limit of an api.
+type Discovery struct {
+	//Step is the share of the configured limit added with every step, such as 0.1 for 10%.
+	//At least one request is added.
+	Step float64
+	//Interval is how long the host must go without a 429 before the limit is raised again.
+	//It is at least one time period of the config, so every limit is used for a whole period first.
+	Interval time.Duration
+	//Max is the highest multiple of the configured limit discovery tries. 0 uses 2.
+	//The highest bound of SetLimitBounds is respected as well.
+	Max float64
+}
+
+//SetDiscovery lets a host discover its real rate limit. Whenever the host has gone for the Interval
+//without a 429, a successful request raises the request limit by one Step. The first 429 above the
+//configured limit settles the limit on the last rate that was free of errors, and discovery stops.
+//The learned limit is saved in redis like any other adjustment, until ResetConfig or UpdateConfig.
+//
+//	config.SetDiscovery(Discovery{Step: 0.1, Interval: time.Minute})
+func (rl *RateLimitConfig) SetDiscovery(discovery Discovery) {
+	rl.discovery = discovery
+}
+
+//validate returns an error if the discovery is not possible.
+func (d Discovery) validate() error {
+	if d.Step < 0 || d.Interval < 0 || d.Max < 0 {
+		return fmt.Errorf("discovery step, interval and max must not be negative, got %v, %v and %v", d.Step, d.Interval, d.Max)
+	}
+
+	if d.Max > 0 && d.Max < 1 {
+		return fmt.Errorf("discovery max must be at least 1, got %v", d.Max)
+	}
+
+	return nil
+}
+
+//isDiscovering returns true while the limit of the host may still be raised
+func (rl RateLimitConfig) isDiscovering() bool {
+	return rl.discovery.Step > 0 && !rl.settled && rl.requestLimit > 0
+}
+
+//nextDiscoveryLimit returns the limit of the next discovery step, or the current limit if it cannot be raised.
+func (rl RateLimitConfig) nextDiscoveryLimit() int {
+	base := rl.baseLimit
+	if base == 0 {
+		base = rl.requestLimit
+	}
+
+	max := rl.discovery.Max
+	if max == 0 {
+		max = defaultDiscoveryMax
+	}
+
+	step := int(float64(base) * rl.discovery.Step)
+	if step < 1 {
+		step = 1
+	}
+
+	limit := rl.requestLimit + step
+	if highest := int(float64(base) * max); limit > highest {
+		limit = highest
+	}
+
+	limit = rl.boundLimit(limit)
+	if limit < rl.requestLimit {
+		return rl.requestLimit
+	}
+
+	return limit
+}
+
+//isDiscoveryDue returns true if the host has gone for the interval of the discovery without
+//a reduction or a raise since the given time.
+func (rl RateLimitConfig) isDiscoveryDue(since int64, now int64) bool {
+	if rl.raisedAt > since {
+		since = rl.raisedAt
+	}
+
+	if rl.reducedAt > since {
+		since = rl.reducedAt
+	}
+
+	interval := durationToMilliseconds(rl.discovery.Interval)
+	if interval < rl.timePeriod {
+		interval = rl.timePeriod
+	}
+
+	return now-since >= interval
+}
+
+//discover raises the limit of the host by one step if it is due. The config is watched, so only one
+//process raises the limit when several of them find it is due at the same time.
+func (l *Limiter) discover() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := getUnixTimeMilliseconds()
+	if !l.config.isDiscovering() || !l.config.isDiscoveryDue(l.status.created, now) {
+		return nil
+	}
+
+	key := l.getConfigKey()
+
+	return l.pool.Do(radix.WithConn(key, func(c radix.Conn) error {
+		if err := c.Do(radix.Cmd(nil, "WATCH", key)); err != nil {
+			return err
+		}
+
+		//another process may have raised or settled the limit since the last decision
+		if err := l.config.updateConfigFromDatabase(c, key); err != nil {
+			c.Do(radix.Cmd(nil, "UNWATCH"))
+			return err
+		}
+
+		limit := l.config.nextDiscoveryLimit()
+		if !l.config.isDiscovering() || !l.config.isDiscoveryDue(l.status.created, now) || limit == l.config.requestLimit {
+			return c.Do(radix.Cmd(nil, "UNWATCH"))
+		}
+
+		if err := c.Do(radix.Cmd(nil, "MULTI")); err != nil {
+			return err
+		}
+
+		var err error
+		defer func() {
+			if err != nil {
+				c.Do(radix.Cmd(nil, "DISCARD"))
+			}
+		}()
+
+		l.config.stableLimit = l.config.requestLimit
+		l.config.requestLimit = limit
+		l.config.raisedAt = now
+		l.config.setTimeBetweenRequests()
+
+		if err = l.saveConfig(c); err != nil {
+			return err
+		}
+
+		//if another process changed the config, the limit it saved is loaded on the next decision
+		return c.Do(radix.Cmd(nil, "EXEC"))
+	}))
+}
+
+//settleDiscovery returns the limit to the last rate that was free of errors if the limit was raised
+//above it by discovery. It returns false if the limit was not raised, so the error is handled as usual.
+func (rl *RateLimitConfig) settleDiscovery() bool {
+	if !rl.isDiscovering() || rl.stableLimit == 0 || rl.requestLimit <= rl.stableLimit {
+		return false
+	}
+
+	rl.requestLimit = rl.stableLimit
+	rl.settled = true
+	rl.setTimeBetweenRequests()
+	return true
+}
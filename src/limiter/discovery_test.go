@@ -0,0 +1,123 @@
+package limiter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mediocregopher/radix/v3"
+)
+
+func Test_NextDiscoveryLimit(t *testing.T) {
+	type TestNextDiscoveryLimit struct {
+		limit     int
+		discovery Discovery
+		max       Window
+		expected  int
+	}
+
+	testCases := []TestNextDiscoveryLimit{
+		{10, Discovery{Step: 0.2}, Window{}, 12},
+		{10, Discovery{Step: 0.01}, Window{}, 11},          //at least one request is added
+		{19, Discovery{Step: 0.2}, Window{}, 20},           //twice the configured limit by default
+		{14, Discovery{Step: 0.5, Max: 1.5}, Window{}, 15}, //the highest multiple is respected
+		{12, Discovery{Step: 0.5}, Window{13, time.Second}, 13},
+		{15, Discovery{Step: 0.5}, Window{13, time.Second}, 15}, //never lowered by a step
+	}
+
+	for i := 0; i < len(testCases); i++ {
+		config := newTestConfig("test_host_1", testCases[i].limit)
+		config.baseLimit = 10
+		config.SetDiscovery(testCases[i].discovery)
+		config.SetLimitBounds(Window{}, testCases[i].max)
+
+		if next := config.nextDiscoveryLimit(); next != testCases[i].expected {
+			t.Errorf("Loop: %v. Expected the next limit to be %v, got: %v", i, testCases[i].expected, next)
+		}
+	}
+
+	config := newTestConfig("test_host_1", 10)
+	config.SetDiscovery(Discovery{Step: 0.1, Max: 0.5})
+	if err := config.Validate(); err == nil {
+		t.Errorf("Expected an error for a discovery below the configured limit")
+	}
+}
+
+func Test_Discovery(t *testing.T) {
+	deleteTestHosts(t, "discoveryHost")
+
+	config := newTestConfig("discoveryHost", 10)
+	config.SetDiscovery(Discovery{Step: 0.2})
+
+	limiter, err := NewLimiter(config, pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type TestDiscoveryStep struct {
+		aged     bool //a whole period has passed since the host was created or its limit was raised
+		expected int
+	}
+
+	testCases := []TestDiscoveryStep{
+		{false, 10}, //the first period has not passed yet
+		{true, 12},
+		{true, 14},
+		{false, 14}, //the limit is raised at most once per period
+	}
+
+	for i := 0; i < len(testCases); i++ {
+		if testCases[i].aged {
+			past := getUnixTimeMilliseconds() - 1000
+			err := pool.Do(radix.Pipeline(
+				radix.FlatCmd(nil, "HSET", "status:discoveryHost", created, past),
+				radix.FlatCmd(nil, "HSET", "config:discoveryHost", raisedAt, past),
+				radix.FlatCmd(nil, "HINCRBY", "config:discoveryHost", configVersion, 1),
+			))
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if canMake, _, _ := limiter.CanMakeRequest(1); !canMake {
+			t.Fatalf("Loop: %v. Expected a request to be approved", i)
+		}
+
+		if err := limiter.RequestSuccessful(1); err != nil {
+			t.Fatal(err)
+		}
+
+		if limiter.config.requestLimit != testCases[i].expected {
+			t.Errorf("Loop: %v. Expected the limit to be %v, got: %v", i, testCases[i].expected, limiter.config.requestLimit)
+		}
+	}
+
+	if canMake, _, _ := limiter.CanMakeRequest(1); !canMake {
+		t.Fatal("Expected a request to be approved")
+	}
+
+	if err := limiter.HitRateLimit(1); err != nil {
+		t.Fatal(err)
+	}
+
+	//discovery has settled, so the limit is not raised again
+	if err := limiter.discover(); err != nil {
+		t.Fatal(err)
+	}
+
+	var saved map[string]string
+	if err := pool.Do(radix.Cmd(&saved, "HGETALL", "config:discoveryHost")); err != nil {
+		t.Fatal(err)
+	}
+
+	if saved[limit] != "12" || saved[settled] != "1" {
+		t.Errorf("Expected the limit to settle on the last error free limit of 12, got: %v settled: %v", saved[limit], saved[settled])
+	}
+
+	if err := limiter.ResetConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	if limiter.config.requestLimit != 10 || limiter.config.settled {
+		t.Errorf("Expected ResetConfig to restart discovery from 10, got: %v settled: %v", limiter.config.requestLimit, limiter.config.settled)
+	}
+}
@@ -0,0 +1,355 @@
+package limiter
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/mediocregopher/radix/v3"
+)
+
+//exportVersion is the version of the documents written by Export
+const exportVersion = 1
+
+//ImportMode decides what Import does with hosts that already exist in redis
+type ImportMode int
+
+const (
+	//ImportMerge only adds the status and config of hosts that are missing from redis
+	ImportMerge ImportMode = iota
+	//ImportOverwrite replaces the status and config of every host in the document
+	ImportOverwrite
+)
+
+//exportDocument is the JSON document written by Export and read by Import
+type exportDocument struct {
+	Version int                     `json:"version"`
+	Hosts   map[string]exportedHost `json:"hosts"`
+}
+
+//exportedHost is the state of one host, either part of which can be missing
+type exportedHost struct {
+	Status *RequestsStatus  `json:"status,omitempty"`
+	Config *RateLimitConfig `json:"config,omitempty"`
+}
+
+//statusJSON is the JSON form of a RequestsStatus
+type statusJSON struct {
+	Requests        int   `json:"requests"`
+	PendingRequests int   `json:"pendingRequests"`
+	FirstRequest    int64 `json:"firstRequest"`
+	LastErrorTime   int64 `json:"lastErrorTime"`
+	Bans            int   `json:"bans"`
+	BanUntil        int64 `json:"banUntil"`
+	Probes          int   `json:"probes"`
+	Probing         int   `json:"probing"`
+	Created         int64 `json:"created"`
+}
+
+//configJSON is the JSON form of a RateLimitConfig
+type configJSON struct {
+	Host                string `json:"host"`
+	RequestLimit        int    `json:"limit"`
+	TimePeriod          int64  `json:"timePeriodMs"`
+	TimeBetweenRequests int64  `json:"timeBetween"`
+	Version             int64  `json:"version"`
+	BaseLimit           int    `json:"baseLimit"`
+	ReducedAt           int64  `json:"reducedAt"`
+	StableLimit         int    `json:"stableLimit"`
+	RaisedAt            int64  `json:"raisedAt"`
+	Settled             bool   `json:"settled"`
+
+	Settings configSettings `json:"settings"`
+}
+
+//MarshalJSON returns the status as JSON, with every time in milliseconds
+func (r RequestsStatus) MarshalJSON() ([]byte, error) {
+	return json.Marshal(statusJSON{
+		Requests:        r.requests,
+		PendingRequests: r.pendingRequests,
+		FirstRequest:    r.firstRequest,
+		LastErrorTime:   r.lastErrorTime,
+		Bans:            r.bans,
+		BanUntil:        r.banUntil,
+		Probes:          r.probes,
+		Probing:         r.probing,
+		Created:         r.created,
+	})
+}
+
+//UnmarshalJSON sets the status from JSON written by MarshalJSON
+func (r *RequestsStatus) UnmarshalJSON(data []byte) error {
+	var s statusJSON
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	*r = RequestsStatus{
+		requests:        s.Requests,
+		pendingRequests: s.PendingRequests,
+		firstRequest:    s.FirstRequest,
+		lastErrorTime:   s.LastErrorTime,
+		bans:            s.Bans,
+		banUntil:        s.BanUntil,
+		probes:          s.Probes,
+		probing:         s.Probing,
+		created:         s.Created,
+	}
+	return nil
+}
+
+//MarshalJSON returns the config as JSON. Times and periods are in milliseconds, while the durations of
+//settings such as the ban policy are in nanoseconds, as time.Duration is.
+func (rl RateLimitConfig) MarshalJSON() ([]byte, error) {
+	return json.Marshal(configJSON{
+		Host:                rl.host,
+		RequestLimit:        rl.requestLimit,
+		TimePeriod:          rl.timePeriod,
+		TimeBetweenRequests: rl.timeBetweenRequests,
+		Version:             rl.version,
+		BaseLimit:           rl.baseLimit,
+		ReducedAt:           rl.reducedAt,
+		StableLimit:         rl.stableLimit,
+		RaisedAt:            rl.raisedAt,
+		Settled:             rl.settled,
+		Settings:            rl.getSettings(),
+	})
+}
+
+//UnmarshalJSON sets the config from JSON written by MarshalJSON.
+func (rl *RateLimitConfig) UnmarshalJSON(data []byte) error {
+	var c configJSON
+	if err := json.Unmarshal(data, &c); err != nil {
+		return err
+	}
+
+	rl.host = c.Host
+	rl.requestLimit = c.RequestLimit
+	rl.timePeriod = c.TimePeriod
+	rl.timeBetweenRequests = c.TimeBetweenRequests
+	rl.version = c.Version
+	rl.baseLimit = c.BaseLimit
+	rl.reducedAt = c.ReducedAt
+	rl.stableLimit = c.StableLimit
+	rl.raisedAt = c.RaisedAt
+	rl.settled = c.Settled
+	rl.setSettings(c.Settings)
+	return nil
+}
+
+//Export returns the status and config of every host under the key prefix, which is empty for limiters
+//created without WithKeyPrefix, in the redis database of the pool as a versioned JSON document, for
+//incident analysis or to move the state to another redis database with Import.
+//
+//	data, err := Export(pool, "")
+func Export(pool *radix.Pool, prefix string) ([]byte, error) {
+	if err := validateKeyPrefix(prefix); err != nil {
+		return nil, err
+	}
+
+	document := exportDocument{
+		Version: exportVersion,
+		Hosts:   make(map[string]exportedHost),
+	}
+
+	statusHosts, err := scanHosts(pool, prefix, statusKind)
+	if err != nil {
+		return nil, err
+	}
+
+	configHosts, err := scanHosts(pool, prefix, configKind)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, host := range statusHosts {
+		status := RequestsStatus{}
+		key := formatKey(prefix, statusKind, host)
+		if err := pool.Do(radix.WithConn(key, func(c radix.Conn) error {
+			return status.updateStatusFromDatabase(c, key)
+		})); err != nil {
+			return nil, err
+		}
+
+		//the status may have been deleted since it was scanned
+		if status != (RequestsStatus{}) {
+			exported := document.Hosts[host]
+			exported.Status = &status
+			document.Hosts[host] = exported
+		}
+	}
+
+	for _, host := range configHosts {
+		config := RateLimitConfig{host: host}
+		key := formatKey(prefix, configKind, host)
+		if err := pool.Do(radix.WithConn(key, func(c radix.Conn) error {
+			return config.updateConfigFromDatabase(c, key)
+		})); err != nil {
+			return nil, err
+		}
+
+		//every saved config has a version, unless it was deleted since it was scanned
+		if config.version > 0 {
+			exported := document.Hosts[host]
+			exported.Config = &config
+			document.Hosts[host] = exported
+		}
+	}
+
+	return json.MarshalIndent(document, "", "\t")
+}
+
+//Import saves the status and config of every host in a document written by Export to the redis database
+//of the pool, under the key prefix. With ImportMerge, hosts that already have a status or config in redis
+//keep it, while ImportOverwrite replaces them. Every Limiter using an imported config loads it on its next decision.
+//
+//	err := Import(pool, "", data, ImportMerge)
+func Import(pool *radix.Pool, prefix string, data []byte, mode ImportMode) error {
+	if err := validateKeyPrefix(prefix); err != nil {
+		return err
+	}
+
+	var document exportDocument
+	if err := json.Unmarshal(data, &document); err != nil {
+		return err
+	}
+
+	if document.Version < 1 || document.Version > exportVersion {
+		return fmt.Errorf("unsupported export version %v, expected at most %v", document.Version, exportVersion)
+	}
+
+	for host, exported := range document.Hosts {
+		if err := importHost(pool, prefix, host, exported, mode); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//importHost saves the status and config of one host, trying again if the keys change while it is saved
+func importHost(pool *radix.Pool, prefix string, host string, exported exportedHost, mode ImportMode) error {
+	statusKey := formatKey(prefix, statusKind, host)
+	configKey := formatKey(prefix, configKind, host)
+	eventsChannel := formatKey(prefix, eventsKind, host)
+
+	for {
+		var aborted bool
+		err := pool.Do(radix.WithConn(statusKey, func(c radix.Conn) error {
+			var err error
+			aborted, err = tryImportHost(c, statusKey, configKey, eventsChannel, exported, mode)
+			return err
+		}))
+
+		if err != nil || !aborted {
+			return err
+		}
+	}
+}
+
+//tryImportHost saves the status and config of one host in a transaction. It returns true if the
+//transaction was aborted because one of the keys changed.
+func tryImportHost(c radix.Conn, statusKey, configKey, eventsChannel string, exported exportedHost, mode ImportMode) (aborted bool, err error) {
+	//must be done before the multi call
+	if err := c.Do(radix.Cmd(nil, "WATCH", statusKey, configKey)); err != nil {
+		return false, err
+	}
+
+	writeStatus := exported.Status != nil
+	writeConfig := exported.Config != nil
+
+	if mode == ImportMerge {
+		var statusExists, configExists int
+		if err := c.Do(radix.Cmd(&statusExists, "EXISTS", statusKey)); err != nil {
+			return false, err
+		}
+
+		if err := c.Do(radix.Cmd(&configExists, "EXISTS", configKey)); err != nil {
+			return false, err
+		}
+
+		writeStatus = writeStatus && statusExists == 0
+		writeConfig = writeConfig && configExists == 0
+	}
+
+	if !writeStatus && !writeConfig {
+		return false, c.Do(radix.Cmd(nil, "UNWATCH"))
+	}
+
+	if err := c.Do(radix.Cmd(nil, "MULTI")); err != nil {
+		return false, err
+	}
+
+	defer func() {
+		if err != nil {
+			//err doesn't matter. any error is a network err, so client will close conn.
+			c.Do(radix.Cmd(nil, "DISCARD"))
+		}
+	}()
+
+	if writeStatus {
+		status := exported.Status
+		if err = c.Do(radix.Cmd(nil, "DEL", statusKey)); err != nil {
+			return false, err
+		}
+
+		err = c.Do(radix.FlatCmd(nil, "HSET",
+			statusKey,
+			requests, status.requests,
+			pendingRequests, status.pendingRequests,
+			firstRequest, status.firstRequest,
+			lastErrorTime, status.lastErrorTime,
+			bans, status.bans,
+			banUntil, status.banUntil,
+			probes, status.probes,
+			probing, status.probing,
+			created, status.created,
+		))
+
+		if err != nil {
+			return false, err
+		}
+	}
+
+	if writeConfig {
+		config := exported.Config
+		configSettings, err := config.settingsToDatabase()
+		if err != nil {
+			return false, err
+		}
+
+		//every field is replaced, but the hash is kept so its version keeps counting up
+		err = c.Do(radix.FlatCmd(nil, "HSET",
+			configKey,
+			limit, config.requestLimit,
+			timePeriodMs, config.timePeriod,
+			timeBetweenRequests, config.timeBetweenRequests,
+			baseLimit, config.baseLimit,
+			reducedAt, config.reducedAt,
+			stableLimit, config.stableLimit,
+			raisedAt, config.raisedAt,
+			settled, config.settled,
+			settings, configSettings,
+		))
+
+		if err != nil {
+			return false, err
+		}
+
+		//the existing version is raised rather than replaced, so it differs from every cached copy of the config
+		if err = c.Do(radix.FlatCmd(nil, "HINCRBY", configKey, configVersion, 1)); err != nil {
+			return false, err
+		}
+
+		if err = c.Do(radix.Cmd(nil, "PUBLISH", eventsChannel, eventConfig)); err != nil {
+			return false, err
+		}
+	}
+
+	var resp []interface{}
+	if err = c.Do(radix.Cmd(&resp, "EXEC")); err != nil {
+		return false, err
+	}
+
+	//a nil response means one of the watched keys changed
+	return resp == nil, nil
+}
@@ -0,0 +1,137 @@
+package limiter
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/mediocregopher/radix/v3"
+)
+
+func Test_StatusAndConfigJSON(t *testing.T) {
+	status := RequestsStatus{requests: 3, pendingRequests: 1, firstRequest: 1000, bans: 2, banUntil: 5000, created: 900}
+
+	data, err := json.Marshal(status)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decodedStatus RequestsStatus
+	if err := json.Unmarshal(data, &decodedStatus); err != nil {
+		t.Fatal(err)
+	}
+
+	if decodedStatus != status {
+		t.Errorf("Expected the status %+v after a round trip, got: %+v", status, decodedStatus)
+	}
+
+	config := newTestConfig("test_host_1", 10)
+	config.version = 4
+	config.reducedAt = 2000
+
+	data, err = json.Marshal(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decodedConfig RateLimitConfig
+	if err := json.Unmarshal(data, &decodedConfig); err != nil {
+		t.Fatal(err)
+	}
+
+	if decodedConfig.host != config.host || decodedConfig.requestLimit != 10 || decodedConfig.timePeriod != 1000 ||
+		decodedConfig.version != 4 || decodedConfig.reducedAt != 2000 {
+		t.Errorf("Expected the config %+v after a round trip, got: %+v", config, decodedConfig)
+	}
+}
+
+func Test_ExportImport(t *testing.T) {
+	deleteTestHosts(t, "exportHost")
+
+	limiter, err := NewLimiter(newTestConfig("exportHost", 10), pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := limiter.HitRateLimit(1); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := Export(pool, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type TestImport struct {
+		mode     ImportMode
+		current  int //limit in redis before the import
+		expected int
+	}
+
+	testCases := []TestImport{
+		{ImportMerge, 0, 9},      //the host is missing, so it is added
+		{ImportMerge, 20, 20},    //the host exists, so it is kept
+		{ImportOverwrite, 20, 9}, //the host exists, but is replaced
+	}
+
+	for i := 0; i < len(testCases); i++ {
+		deleteTestHosts(t, "exportHost")
+		if testCases[i].current > 0 {
+			if err := pool.Do(radix.FlatCmd(nil, "HSET", "config:exportHost", limit, testCases[i].current)); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if err := Import(pool, "", data, testCases[i].mode); err != nil {
+			t.Fatal(err)
+		}
+
+		var imported int
+		if err := pool.Do(radix.Cmd(&imported, "HGET", "config:exportHost", limit)); err != nil {
+			t.Fatal(err)
+		}
+
+		if imported != testCases[i].expected {
+			t.Errorf("Loop: %v. Expected a limit of %v, got: %v", i, testCases[i].expected, imported)
+		}
+	}
+
+	if err := Import(pool, "", []byte(`{"version": 2, "hosts": {}}`), ImportOverwrite); err == nil {
+		t.Errorf("Expected an error for a document of an unknown version")
+	}
+}
+
+func Test_ImportReloadsCachedConfigs(t *testing.T) {
+	deleteTestHosts(t, "importHost")
+
+	limiter, err := NewLimiter(newTestConfig("importHost", 10), pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//the limiter caches the config at its current version
+	if _, _, err := limiter.AcquireN(context.Background(), 1, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	//a document whose config is one version behind the one the limiter cached
+	config := newTestConfig("importHost", 4)
+	config.version = limiter.config.version - 1
+	data, err := json.Marshal(exportDocument{Version: exportVersion, Hosts: map[string]exportedHost{"importHost": {Config: &config}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Import(pool, "", data, ImportOverwrite); err != nil {
+		t.Fatal(err)
+	}
+
+	granted, _, err := limiter.AcquireN(context.Background(), 10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if granted != 3 {
+		t.Errorf("Expected the imported limit of 4 to be loaded, got %v more requests", granted)
+	}
+}
@@ -0,0 +1,202 @@
+package limiter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mediocregopher/radix/v3"
+)
+
+//minConsumerTimeout is the shortest time in milliseconds a consumer stays active after it last
+//tried to make a request. Consumers are active for at least two periods of the host.
+const minConsumerTimeout = 1000
+
+//consumerShare is what the database knows about one consumer of a host.
+//It is saved in the consumers hash of the host as weight:seen:period:used.
+type consumerShare struct {
+	weight int   //share of the request limit relative to the other consumers
+	seen   int64 //last time in milliseconds the consumer tried to make a request
+	period int64 //firstRequest of the period the used requests were made in
+	used   int   //sum of the weights of the requests the consumer made in that period
+}
+
+//WithConsumer identifies who is making a request, so the request limit of a host can be shared fairly
+//between the services that use it. Every active consumer is guaranteed a share of the request limit
+//in proportion to its shareWeight. Requests are only denied to a consumer that has used up its share when
+//the rest of the limit is needed for the guaranteed shares of other consumers. The shares of consumers
+//that stop making requests go to the others.
+//
+//	canMake, sleepTime, err := limiter.CanMakeRequest(1, WithConsumer("backfill", 1))
+//A consumer is active while it keeps trying to make requests, including requests that are denied.
+func WithConsumer(id string, shareWeight int) AcquireOption {
+	if shareWeight <= 0 {
+		shareWeight = 1
+	}
+
+	return func(o *acquireOptions) {
+		o.consumer = id
+		o.consumerWeight = shareWeight
+	}
+}
+
+//updateConsumersFromDatabase gets the shares of every consumer of the host from the database
+func (l *Limiter) updateConsumersFromDatabase(c radix.Conn) error {
+	var values map[string]string
+	if err := c.Do(radix.Cmd(&values, "HGETALL", l.getConsumersKey())); err != nil {
+		return err
+	}
+
+	l.consumers = make(map[string]consumerShare, len(values))
+	for id, value := range values {
+		share, err := parseConsumerShare(value)
+		if err != nil {
+			//a share that cannot be read is treated as a consumer that has never made a request
+			continue
+		}
+		l.consumers[id] = share
+	}
+
+	return nil
+}
+
+//fairShareAllows checks if the consumer can make a request without using the guaranteed share of
+//another active consumer. limit is the request limit of the period that began at periodStart and used
+//is how much of it has already been used, not including the request.
+func (l *Limiter) fairShareAllows(options acquireOptions, requestWeight int, limit int, used int, periodStart int64, now int64) bool {
+	timeout := l.consumerTimeout()
+
+	var totalWeight int
+	for id, share := range l.consumers {
+		if id != options.consumer && now-share.seen < timeout {
+			totalWeight += share.weight
+		}
+	}
+	totalWeight += options.consumerWeight
+
+	consumerUsed := l.consumers[options.consumer].usedIn(periodStart)
+	guaranteed := float64(limit*options.consumerWeight) / float64(totalWeight)
+	if float64(consumerUsed+requestWeight) <= guaranteed {
+		return true
+	}
+
+	//the part of the guaranteed shares of the other active consumers they have not used yet
+	var reservedForOthers float64
+	for id, share := range l.consumers {
+		if id == options.consumer || now-share.seen >= timeout {
+			continue
+		}
+
+		unused := float64(limit*share.weight)/float64(totalWeight) - float64(share.usedIn(periodStart))
+		if unused > 0 {
+			reservedForOthers += unused
+		}
+	}
+
+	return float64(used+requestWeight)+reservedForOthers <= float64(limit)
+}
+
+//saveConsumer saves the share of the consumer making a request to the database. If made is true the
+//request weight is added to what the consumer used in the current period, otherwise the consumer is only
+//marked as active. Consumers that have not tried to make a request for a long time are removed.
+func (l *Limiter) saveConsumer(c radix.Conn, options acquireOptions, requestWeight int, made bool, now int64) error {
+	key := l.getConsumersKey()
+
+	share := l.consumers[options.consumer]
+	share.weight = options.consumerWeight
+	share.seen = now
+	if made {
+		share.used = share.usedIn(l.status.firstRequest) + requestWeight
+		share.period = l.status.firstRequest
+	}
+
+	if err := c.Do(radix.Cmd(nil, "HSET", key, options.consumer, share.String())); err != nil {
+		return err
+	}
+
+	for id, other := range l.consumers {
+		if id != options.consumer && now-other.seen > 10*l.consumerTimeout() {
+			if err := c.Do(radix.Cmd(nil, "HDEL", key, id)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+//markConsumerActive saves that a consumer that was denied a request is still active, inside of the transaction
+//on the watched consumers of the limiters. If another process changed the shares first, nothing is saved, so the
+//requests it added are kept, and the consumer is marked active again by its next attempt.
+func markConsumerActive(c radix.Conn, limiters []*Limiter, options acquireOptions) error {
+	if err := c.Do(radix.Cmd(nil, "MULTI")); err != nil {
+		return err
+	}
+
+	var err error
+	defer func() {
+		if err != nil {
+			c.Do(radix.Cmd(nil, "DISCARD"))
+		}
+	}()
+
+	now := getUnixTimeMilliseconds()
+	for _, l := range limiters {
+		if err = l.saveConsumer(c, options, 0, false, now); err != nil {
+			return err
+		}
+	}
+
+	return c.Do(radix.Cmd(nil, "EXEC"))
+}
+
+//consumerTimeout is how long in milliseconds a consumer stays active after it last tried to make a request
+func (l *Limiter) consumerTimeout() int64 {
+	if timeout := 2 * l.config.timePeriod; timeout > minConsumerTimeout {
+		return timeout
+	}
+
+	return minConsumerTimeout
+}
+
+//usedIn returns how much of the request limit the consumer used in the period that began at periodStart
+func (s consumerShare) usedIn(periodStart int64) int {
+	if s.period != periodStart {
+		return 0
+	}
+
+	return s.used
+}
+
+func (s consumerShare) String() string {
+	return fmt.Sprintf("%v:%v:%v:%v", s.weight, s.seen, s.period, s.used)
+}
+
+func parseConsumerShare(value string) (consumerShare, error) {
+	parts := strings.Split(value, ":")
+	if len(parts) != 4 {
+		return consumerShare{}, fmt.Errorf("consumer share %q does not have 4 parts", value)
+	}
+
+	weight, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return consumerShare{}, err
+	}
+
+	seen, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return consumerShare{}, err
+	}
+
+	period, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return consumerShare{}, err
+	}
+
+	used, err := strconv.Atoi(parts[3])
+	if err != nil {
+		return consumerShare{}, err
+	}
+
+	return consumerShare{weight, seen, period, used}, nil
+}
@@ -0,0 +1,144 @@
+package limiter
+
+import (
+	"testing"
+
+	"github.com/mediocregopher/radix/v3"
+)
+
+func Test_FairShareAllows(t *testing.T) {
+	now := getUnixTimeMilliseconds()
+	var periodStart int64 = 1000
+
+	type TestFairShare struct {
+		name      string
+		consumers map[string]consumerShare
+		weight    int
+		used      int
+		expected  bool
+	}
+
+	testCases := []TestFairShare{
+		{
+			"only consumer can use the whole limit",
+			map[string]consumerShare{"a": {1, now, periodStart, 9}},
+			1,
+			9,
+			true,
+		},
+		{
+			"within guaranteed share",
+			map[string]consumerShare{"a": {1, now, periodStart, 4}, "b": {1, now, periodStart, 0}},
+			1,
+			4,
+			true,
+		},
+		{
+			"over guaranteed share while the other consumer has not used its share",
+			map[string]consumerShare{"a": {1, now, periodStart, 5}, "b": {1, now, periodStart, 1}},
+			1,
+			6,
+			false,
+		},
+		{
+			"share of an idle consumer goes to the others",
+			map[string]consumerShare{"a": {1, now, periodStart, 8}, "b": {1, now - 10*minConsumerTimeout, periodStart, 0}},
+			1,
+			8,
+			true,
+		},
+		{
+			"usage from an earlier period does not count",
+			map[string]consumerShare{"a": {1, now, periodStart - 1, 9}, "b": {1, now, periodStart, 0}},
+			1,
+			0,
+			true,
+		},
+		{
+			"heavier consumer gets a larger guaranteed share",
+			map[string]consumerShare{"a": {3, now, periodStart, 6}, "b": {1, now, periodStart, 0}},
+			3,
+			6,
+			true,
+		},
+	}
+
+	for i := 0; i < len(testCases); i++ {
+		t.Run(testCases[i].name, func(t *testing.T) {
+			l := Limiter{host: "test_host_1", config: NewRateLimitConfig("test_host_1", 10, 1, 10, 1, 0), consumers: testCases[i].consumers}
+			options := newAcquireOptions([]AcquireOption{WithConsumer("a", testCases[i].weight)})
+
+			result := l.fairShareAllows(options, 1, 10, testCases[i].used, periodStart, now)
+			if result != testCases[i].expected {
+				t.Errorf("Loop: %v. Expected %v, got: %v", i, testCases[i].expected, result)
+			}
+		})
+	}
+}
+
+func Test_ParseConsumerShare(t *testing.T) {
+	share := consumerShare{2, 1234, 1000, 7}
+
+	parsed, err := parseConsumerShare(share.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if parsed != share {
+		t.Errorf("Expected %v, got: %v", share, parsed)
+	}
+
+	if _, err := parseConsumerShare("2:1234"); err == nil {
+		t.Errorf("Expected an error for a share with missing parts")
+	}
+}
+
+func Test_CanMakeRequestWithConsumer(t *testing.T) {
+	deleteTestHosts(t, "fairShareHost")
+
+	limiter, err := NewLimiter(newTestConfig("fairShareHost", 10), pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//b makes itself active
+	if canMake, _, err := limiter.CanMakeRequest(1, WithConsumer("b", 1)); err != nil || !canMake {
+		t.Fatalf("Expected the first request of b to be allowed, got: %v, %v", canMake, err)
+	}
+
+	granted := 0
+	for i := 0; i < 10; i++ {
+		canMake, _, err := limiter.CanMakeRequest(1, WithConsumer("a", 1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if canMake {
+			granted++
+		}
+	}
+
+	if granted != 5 {
+		t.Errorf("Expected a to be limited to its share of 5 requests, got: %v", granted)
+	}
+
+	//the denied requests mark a as active without losing the requests it made
+	var value string
+	if err := pool.Do(radix.Cmd(&value, "HGET", "consumers:fairShareHost", "a")); err != nil {
+		t.Fatal(err)
+	}
+
+	share, err := parseConsumerShare(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if share.used != 5 || getUnixTimeMilliseconds()-share.seen > 1000 {
+		t.Errorf("Expected a to be active with 5 requests used, got: %v", value)
+	}
+
+	for i := 0; i < 4; i++ {
+		if canMake, _, err := limiter.CanMakeRequest(1, WithConsumer("b", 1)); err != nil || !canMake {
+			t.Errorf("Expected b to be able to use the rest of its share, got: %v, %v", canMake, err)
+		}
+	}
+}
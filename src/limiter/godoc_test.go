@@ -9,7 +9,9 @@ func ExampleLimiter_WaitForRatelimit() {
 	}
 
 	for {
-		limiter.WaitForRatelimit(1)
+		if err := limiter.WaitForRatelimit(1); err != nil {
+			//handle error
+		}
 
 		//make api request
 		statusCode, err := getStatusCode("www.example.com", 1)
@@ -0,0 +1,76 @@
+package limiter
+
+import (
+	"fmt"
+
+	"github.com/mediocregopher/radix/v3"
+)
+
+//Option configures a Limiter when it is created by NewLimiter.
+type Option func(*Limiter)
+
+//WithParent attaches the new Limiter to a parent Limiter. Every request made through the child
+//must fit into the budgets of both the child and all of its ancestors, and is counted against all of them
+//in a single transaction.
+//
+//This models apis that enforce an account wide ratelimit as well as tighter ratelimits on certain endpoints:
+//	account, err := NewLimiter(accountConfig, pool)
+//	orders, err := NewLimiter(orderConfig, pool, WithParent(account))
+//The parent must use the same redis database as the child and have a different host name.
+func WithParent(parent *Limiter) Option {
+	return func(l *Limiter) {
+		l.parent = parent
+	}
+}
+
+//levels returns the limiter followed by all of its ancestors, starting with its parent.
+func (l *Limiter) levels() []*Limiter {
+	levels := []*Limiter{l}
+	for parent := l.parent; parent != nil; parent = parent.parent {
+		levels = append(levels, parent)
+	}
+
+	return levels
+}
+
+//validateLevels makes sure no two levels of the hierarchy share a host name, since they would
+//count every request twice against the same budget.
+func (l *Limiter) validateLevels() error {
+	hosts := make(map[string]bool)
+	for _, level := range l.levels() {
+		if hosts[level.host] {
+			return fmt.Errorf("host %v is used by more than one level of the limiter hierarchy", level.host)
+		}
+		hosts[level.host] = true
+	}
+
+	return nil
+}
+
+//limitedLevel determines which level of the hierarchy has used the largest share of its request limit.
+//It is the level most likely to have caused a 429, so it is the one whose config gets adjusted.
+func (l *Limiter) limitedLevel(c radix.Conn) (*Limiter, error) {
+	limited := l
+	var limitedUsed, limitedLimit int
+
+	for _, level := range l.levels() {
+		if err := level.status.updateStatusFromDatabase(c, level.getStatusKey()); err != nil {
+			return nil, err
+		}
+
+		//infinite rates can not be the cause of a 429
+		if level.config.requestLimit == 0 {
+			continue
+		}
+
+		used := level.status.requests + level.status.pendingRequests
+		//compares used/requestLimit between the levels without dividing
+		if limitedLimit == 0 || used*limitedLimit > limitedUsed*level.config.requestLimit {
+			limited = level
+			limitedUsed = used
+			limitedLimit = level.config.requestLimit
+		}
+	}
+
+	return limited, nil
+}
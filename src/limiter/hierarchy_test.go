@@ -0,0 +1,118 @@
+package limiter
+
+import (
+	"testing"
+
+	"github.com/mediocregopher/radix/v3"
+)
+
+func Test_HierarchyCanMakeRequest(t *testing.T) {
+	deleteTestHosts(t, "hierarchyAccount", "hierarchyOrders", "hierarchyData")
+
+	account, err := NewLimiter(newTestConfig("hierarchyAccount", 3), pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	orders, err := NewLimiter(newTestConfig("hierarchyOrders", 2), pool, WithParent(account))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := NewLimiter(newTestConfig("hierarchyData", 10), pool, WithParent(account))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type TestRequest struct {
+		name     string
+		limiter  *Limiter
+		expected bool
+	}
+
+	testCases := []TestRequest{
+		{"first order", orders, true},
+		{"second order", orders, true},
+		{"order limit reached", orders, false},
+		{"data request uses the rest of the account limit", data, true},
+		{"account limit reached", data, false},
+	}
+
+	for i := 0; i < len(testCases); i++ {
+		canMake, wait, err := testCases[i].limiter.CanMakeRequest(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if canMake != testCases[i].expected {
+			t.Errorf("%v: expected ability to make request: %v, got: %v", testCases[i].name, testCases[i].expected, canMake)
+		}
+
+		if !canMake && wait <= 0 {
+			t.Errorf("%v: expected a wait time when the request cannot be made, got: %v", testCases[i].name, wait)
+		}
+	}
+
+	if err := orders.RequestCancelled(1); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := pool.Do(radix.WithConn("", func(c radix.Conn) error {
+		for _, level := range []*Limiter{orders, account} {
+			if err := level.status.updateStatusFromDatabase(c, level.getStatusKey()); err != nil {
+				return err
+			}
+		}
+		return nil
+	})); err != nil {
+		t.Fatal(err)
+	}
+
+	if orders.status.pendingRequests != 1 || account.status.pendingRequests != 2 {
+		t.Errorf("Expected cancelling to free capacity at both levels, got: %v, %v", orders.status, account.status)
+	}
+}
+
+func Test_HierarchyHitRateLimit(t *testing.T) {
+	deleteTestHosts(t, "hierarchyAccount2", "hierarchyOrders2")
+
+	account, err := NewLimiter(newTestConfig("hierarchyAccount2", 10), pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	orders, err := NewLimiter(newTestConfig("hierarchyOrders2", 3), pool, WithParent(account))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if canMake, _, err := orders.CanMakeRequest(1); err != nil || !canMake {
+			t.Fatalf("Expected request %v to be allowed, got: %v, %v", i, canMake, err)
+		}
+	}
+
+	//the orders level used 3/3 of its limit while the account level used 3/10
+	if err := orders.HitRateLimit(1); err != nil {
+		t.Fatal(err)
+	}
+
+	if orders.config.requestLimit != 2 {
+		t.Errorf("Expected the orders limit to be reduced to 2, got: %v", orders.config.requestLimit)
+	}
+
+	if account.config.requestLimit != 10 {
+		t.Errorf("Expected the account limit to stay at 10, got: %v", account.config.requestLimit)
+	}
+}
+
+func Test_HierarchySameHost(t *testing.T) {
+	account, err := NewLimiter(newTestConfig("hierarchyAccount3", 10), pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewLimiter(newTestConfig("hierarchyAccount3", 3), pool, WithParent(account)); err == nil {
+		t.Errorf("Expected an error when a child has the same host name as its parent")
+	}
+}
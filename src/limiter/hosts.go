@@ -0,0 +1,135 @@
+package limiter
+
+import (
+	"sort"
+	"time"
+
+	"github.com/mediocregopher/radix/v3"
+)
+
+//WithKeyTTL lets the redis keys of the host expire once the host has not been used for the given time,
+//so hosts that are no longer called do not stay in redis forever. The time is refreshed every time a
+//request is granted, finishes or is cancelled. It should be much longer than the longest wait for a request.
+//
+//	limiter, err := NewLimiter(config, pool, WithKeyTTL(24*time.Hour))
+//A learned config expires with the rest of the host, after which the config given to NewLimiter is used again.
+func WithKeyTTL(ttl time.Duration) Option {
+	return func(l *Limiter) {
+		l.keyTTL = durationToMilliseconds(ttl)
+	}
+}
+
+//refreshKeys restarts the time to live of every key of the host, if the Limiter was created WithKeyTTL
+func (l *Limiter) refreshKeys(c radix.Conn) error {
+	if l.keyTTL <= 0 {
+		return nil
+	}
+
+	for _, key := range hostKeys(l.prefix, l.host) {
+		//keys that do not exist are skipped by redis
+		if err := c.Do(radix.FlatCmd(nil, "PEXPIRE", key, l.keyTTL)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//ListHosts returns the sorted names of every host with a status or config under the key prefix,
+//which is empty for limiters created without WithKeyPrefix. The keys are found with SCAN.
+//
+//	hosts, err := ListHosts(pool, "")
+func ListHosts(pool *radix.Pool, prefix string) ([]string, error) {
+	if err := validateKeyPrefix(prefix); err != nil {
+		return nil, err
+	}
+
+	found := make(map[string]bool)
+	for _, kind := range []string{statusKind, configKind} {
+		hosts, err := scanHosts(pool, prefix, kind)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, host := range hosts {
+			found[host] = true
+		}
+	}
+
+	hosts := make([]string, 0, len(found))
+	for host := range found {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(hosts)
+
+	return hosts, nil
+}
+
+//DeleteHost removes every key of the host under the key prefix, including its learned config.
+//A Limiter that still uses the host keeps its cached config and saves its status again on its next decision.
+func DeleteHost(pool *radix.Pool, prefix string, host string) error {
+	if err := validateKeyPrefix(prefix); err != nil {
+		return err
+	}
+
+	return pool.Do(radix.Cmd(nil, "DEL", hostKeys(prefix, host)...))
+}
+
+//ResetHost clears the status of the host under the key prefix, such as its requests, bans and cooldown,
+//along with its consumers and queue, while keeping its config. Use ResetConfig to undo the adjustments
+//of the config as well. Waiters of the host are woken up to try again.
+func ResetHost(pool *radix.Pool, prefix string, host string) error {
+	if err := validateKeyPrefix(prefix); err != nil {
+		return err
+	}
+
+	statusKey := formatKey(prefix, statusKind, host)
+
+	return pool.Do(radix.WithConn(statusKey, func(c radix.Conn) error {
+		if err := c.Do(radix.Cmd(nil, "MULTI")); err != nil {
+			return err
+		}
+
+		var err error
+		defer func() {
+			if err != nil {
+				c.Do(radix.Cmd(nil, "DISCARD"))
+			}
+		}()
+
+		//the status is written rather than deleted, so limiters do not keep their cached status
+		err = c.Do(radix.FlatCmd(nil, "HSET",
+			statusKey,
+			requests, 0,
+			pendingRequests, 0,
+			firstRequest, 0,
+			lastErrorTime, 0,
+			bans, 0,
+			banUntil, 0,
+			probes, 0,
+			probing, 0,
+			created, getUnixTimeMilliseconds(),
+		))
+
+		if err != nil {
+			return err
+		}
+
+		err = c.Do(radix.Cmd(nil, "DEL",
+			formatKey(prefix, consumersKind, host),
+			formatKey(prefix, queueKind, host),
+			formatKey(prefix, ticketsKind, host),
+			formatKey(prefix, heartbeatsKind, host),
+		))
+
+		if err != nil {
+			return err
+		}
+
+		if err = c.Do(radix.Cmd(nil, "PUBLISH", formatKey(prefix, eventsKind, host), eventReleased)); err != nil {
+			return err
+		}
+
+		return c.Do(radix.Cmd(nil, "EXEC"))
+	}))
+}
@@ -0,0 +1,101 @@
+package limiter
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mediocregopher/radix/v3"
+)
+
+func Test_KeyTTL(t *testing.T) {
+	deleteTestHosts(t, "ttlHost")
+
+	limiter, err := NewLimiter(newTestConfig("ttlHost", 10), pool, WithKeyTTL(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//the keys lose their time to live, which the next grant restarts
+	if err := pool.Do(radix.Cmd(nil, "PERSIST", "status:ttlHost")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := limiter.AcquireN(context.Background(), 1, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, key := range []string{"status:ttlHost", "config:ttlHost"} {
+		var ttl int64
+		if err := pool.Do(radix.Cmd(&ttl, "PTTL", key)); err != nil {
+			t.Fatal(err)
+		}
+
+		if ttl <= 0 || ttl > durationToMilliseconds(time.Hour) {
+			t.Errorf("Expected %v to expire within an hour, got a time to live of %v", key, ttl)
+		}
+	}
+}
+
+func Test_ListDeleteAndResetHosts(t *testing.T) {
+	const prefix = "hostsTest"
+
+	hosts, err := ListHosts(pool, prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, host := range hosts {
+		if err := DeleteHost(pool, prefix, host); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	limiters := make(map[string]*Limiter)
+	for _, host := range []string{"hostB", "hostA"} {
+		limiter, err := NewLimiter(newTestConfig(host, 10), pool, WithKeyPrefix(prefix))
+		if err != nil {
+			t.Fatal(err)
+		}
+		limiters[host] = limiter
+	}
+
+	if hosts, err = ListHosts(pool, prefix); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(hosts, []string{"hostA", "hostB"}) {
+		t.Errorf("Expected the hosts [hostA hostB], got: %v", hosts)
+	}
+
+	if err := limiters["hostA"].HitBan(1); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ResetHost(pool, prefix, "hostA"); err != nil {
+		t.Fatal(err)
+	}
+
+	granted, _, err := limiters["hostA"].AcquireN(context.Background(), 10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//the ban is gone, but the limit it reduced is kept
+	if granted != 5 {
+		t.Errorf("Expected the reset host to grant 5 requests, got: %v", granted)
+	}
+
+	if err := DeleteHost(pool, prefix, "hostB"); err != nil {
+		t.Fatal(err)
+	}
+
+	if hosts, err = ListHosts(pool, prefix); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(hosts, []string{"hostA"}) {
+		t.Errorf("Expected the hosts [hostA] after hostB was deleted, got: %v", hosts)
+	}
+}
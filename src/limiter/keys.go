@@ -0,0 +1,86 @@
+package limiter
+
+import (
+	"fmt"
+	"strings"
+)
+
+//kinds of redis keys kept for every host, each used as the first part of the key
+//example: status:com.binance.api
+const (
+	statusKind     = "status"
+	configKind     = "config"
+	consumersKind  = "consumers"
+	queueKind      = "queue"
+	ticketsKind    = "tickets"
+	heartbeatsKind = "heartbeats"
+	eventsKind     = "events"
+)
+
+//reservedNames cannot be part of a key prefix, so a prefixed key is never mistaken for the key of another kind
+var reservedNames = []string{statusKind, configKind, consumersKind, queueKind, ticketsKind, heartbeatsKind, eventsKind}
+
+//WithKeyPrefix puts every redis key and channel of the Limiter under the given prefix, so several
+//environments or tenants can share one redis database. The keys of a host become prefix:status:host,
+//prefix:config:host and so on.
+//
+//	limiter, err := NewLimiter(config, pool, WithKeyPrefix("staging"))
+//Every Limiter sharing a host must use the same prefix, and a parent only uses its own prefix.
+//NewLimiter returns an error if the prefix is not valid.
+func WithKeyPrefix(prefix string) Option {
+	return func(l *Limiter) {
+		l.prefix = prefix
+	}
+}
+
+//validateKeyPrefix returns an error if the prefix could make keys collide. An empty prefix is valid.
+func validateKeyPrefix(prefix string) error {
+	if prefix == "" {
+		return nil
+	}
+
+	//the prefix is used in SCAN patterns, which would treat these as wildcards
+	if strings.ContainsAny(prefix, "*?[]\\ ") {
+		return fmt.Errorf("key prefix %q must not contain spaces or any of *?[]\\", prefix)
+	}
+
+	for _, part := range strings.Split(prefix, ":") {
+		if part == "" {
+			return fmt.Errorf("key prefix %q must not start or end with a colon, or contain empty parts", prefix)
+		}
+
+		for _, name := range reservedNames {
+			if strings.EqualFold(part, name) {
+				return fmt.Errorf("key prefix %q must not contain the reserved name %q", prefix, name)
+			}
+		}
+	}
+
+	return nil
+}
+
+//formatKey returns the redis key of the given kind for a host, under the prefix if there is one
+func formatKey(prefix string, kind string, host string) string {
+	return kindPrefix(prefix, kind) + host
+}
+
+//kindPrefix returns the part of every key of the given kind that comes before the host
+func kindPrefix(prefix string, kind string) string {
+	if prefix == "" {
+		return kind + ":"
+	}
+
+	return prefix + ":" + kind + ":"
+}
+
+//hostKeys returns every redis key the library keeps for a host, leaving out its events channel
+func hostKeys(prefix string, host string) []string {
+	return []string{
+		formatKey(prefix, statusKind, host),
+		formatKey(prefix, configKind, host),
+		formatKey(prefix, consumersKind, host),
+		formatKey(prefix, queueKind, host),
+		formatKey(prefix, ticketsKind, host),
+		formatKey(prefix, heartbeatsKind, host),
+	}
+}
@@ -0,0 +1,84 @@
+package limiter
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mediocregopher/radix/v3"
+)
+
+func Test_ValidateKeyPrefix(t *testing.T) {
+	type TestKeyPrefix struct {
+		prefix string
+		valid  bool
+	}
+
+	testCases := []TestKeyPrefix{
+		{"", true},
+		{"staging", true},
+		{"tenant:42", true},
+		{"status", false},
+		{"prod:Config", false},
+		{"prod:", false},
+		{"prod::eu", false},
+		{"prod*", false},
+		{"my app", false},
+	}
+
+	for i := 0; i < len(testCases); i++ {
+		if err := validateKeyPrefix(testCases[i].prefix); (err == nil) != testCases[i].valid {
+			t.Errorf("Loop: %v. Expected %q to be valid: %v, got: %v", i, testCases[i].prefix, testCases[i].valid, err)
+		}
+	}
+
+	if _, err := NewLimiter(newTestConfig("test_host_1", 10), pool, WithKeyPrefix("events")); err == nil {
+		t.Errorf("Expected an error for a reserved key prefix")
+	}
+}
+
+func Test_KeyPrefix(t *testing.T) {
+	deleteTestHosts(t, "prefixHost")
+	deletePrefixedTestHosts(t, "staging", "prefixHost")
+
+	production, err := NewLimiter(newTestConfig("prefixHost", 10), pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	staging, err := NewLimiter(newTestConfig("prefixHost", 10), pool, WithKeyPrefix("staging"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type TestKeyPrefix struct {
+		limiter *Limiter
+		count   int
+		granted int
+	}
+
+	testCases := []TestKeyPrefix{
+		{production, 10, 10},
+		{staging, 4, 4}, //the requests of production do not count against staging
+		{staging, 10, 6},
+	}
+
+	for i := 0; i < len(testCases); i++ {
+		granted, _, err := testCases[i].limiter.AcquireN(context.Background(), testCases[i].count, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if granted != testCases[i].granted {
+			t.Errorf("Loop: %v. Expected %v requests to be granted, got: %v", i, testCases[i].granted, granted)
+		}
+	}
+
+	var pending int
+	if err := pool.Do(radix.Cmd(&pending, "HGET", "staging:status:prefixHost", pendingRequests)); err != nil {
+		t.Fatal(err)
+	}
+
+	if pending != 10 {
+		t.Errorf("Expected 10 pending requests under the prefix, got: %v", pending)
+	}
+}
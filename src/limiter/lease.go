@@ -0,0 +1,189 @@
+package limiter
+
+import (
+	"sync"
+	"time"
+)
+
+//lease is a batch of capacity reserved in redis that is handed out locally.
+type lease struct {
+	size     int           //number of units reserved at a time
+	duration time.Duration //how long unused units are kept before they are returned, 0 for one time period
+
+	mu      sync.Mutex
+	units   int //units left to hand out
+	expires time.Time
+	timer   *time.Timer //returns the unused units once the lease expires
+	grant   Grant       //how the units of the lease were granted
+
+	//the units were reserved for requests of this priority and consumer
+	priority       Priority
+	consumer       string
+	consumerWeight int
+}
+
+//WithLease makes CanMakeRequest reserve size units of capacity at a time and hand them out locally,
+//so most requests do not need a round trip to redis. Unused units are returned to redis when the lease
+//expires after the given duration, when a request does not fit into what is left of the lease, and when
+//the Limiter is closed. A duration of 0 lasts one time period of the config.
+//
+//	limiter, err := NewLimiter(config, pool, WithLease(10, time.Second))
+//Leased units count against the ratelimit for every process as soon as they are reserved, so capacity
+//leased by one process cannot be used by another until it is returned. A lease is reserved with the priority
+//and consumer of the request that reserves it, and its units only go to requests with the same priority and
+//consumer, so they cannot use the reserve of a higher priority or the share of another consumer. Requests
+//with other options are decided by redis while the lease lasts. MultiAcquire does not use leases.
+//
+//RequestSuccessful, HitRateLimit and RequestCancelled must still be called for every request.
+func WithLease(size int, duration time.Duration) Option {
+	return func(l *Limiter) {
+		if size <= 1 {
+			l.lease = nil
+			return
+		}
+
+		l.lease = &lease{size: size, duration: duration}
+	}
+}
+
+//acquireFromLease hands out units of the lease, reserving a new lease in redis when the current one
+//is used up or has expired.
+func (l *Limiter) acquireFromLease(requestWeight int, options acquireOptions) (bool, time.Duration, error) {
+	le := l.lease
+	le.mu.Lock()
+	defer le.mu.Unlock()
+
+	now := time.Now()
+	if le.units > 0 && now.Before(le.expires) && !le.isFor(options) {
+		//the units were reserved under another priority or consumer, so they are kept for those requests
+		return l.reserveLevels(requestWeight, options)
+	}
+
+	if le.units >= requestWeight && now.Before(le.expires) && !l.isCoolingDown() {
+		le.units -= requestWeight
+		le.grant.copyTo(options.grant)
+		return true, 0, nil
+	}
+
+	if err := l.returnLease(); err != nil {
+		return false, 0, err
+	}
+
+	size := le.size
+	if size < requestWeight {
+		size = requestWeight
+	}
+
+	l.mu.Lock()
+	if limit := l.config.forPriority(options.priority).requestLimit; limit > 0 && size > limit {
+		size = limit
+	}
+	duration := le.duration
+	if duration <= 0 {
+		duration = millisecondsToDuration(l.config.timePeriod)
+	}
+	l.mu.Unlock()
+
+	//the grant of the lease is kept for the requests the lease is handed out to
+	requestGrant := options.grant
+	options.grant = &le.grant
+
+	canMake, wait, err := l.reserveLevels(size, options)
+	if err != nil {
+		return false, wait, err
+	}
+
+	//a full lease does not fit, so only the request itself is reserved
+	if !canMake && size > requestWeight {
+		size = requestWeight
+		canMake, wait, err = l.reserveLevels(size, options)
+		if err != nil {
+			return false, wait, err
+		}
+	}
+
+	if !canMake {
+		return false, wait, nil
+	}
+
+	le.grant.copyTo(requestGrant)
+	le.priority = options.priority
+	le.consumer = options.consumer
+	le.consumerWeight = options.consumerWeight
+	le.units = size - requestWeight
+	le.expires = now.Add(duration)
+	if le.units > 0 {
+		le.timer = time.AfterFunc(duration, l.expireLease)
+	}
+	return true, 0, nil
+}
+
+//isFor returns true if the units of the lease were reserved for requests with the options
+func (le *lease) isFor(options acquireOptions) bool {
+	return le.priority == options.priority && le.consumer == options.consumer && le.consumerWeight == options.consumerWeight
+}
+
+//expireLease returns the unused units of an expired lease, so an idle process does not keep them.
+//There is no caller to report an error to, so units that could not be returned are tried again
+//on the next request or on Close.
+func (l *Limiter) expireLease() {
+	le := l.lease
+	le.mu.Lock()
+	defer le.mu.Unlock()
+
+	//a new lease may have been reserved since the timer fired
+	if time.Now().Before(le.expires) {
+		return
+	}
+
+	l.returnLease()
+}
+
+//returnLease gives the unused units of the lease back to redis. The lease must be locked.
+func (l *Limiter) returnLease() error {
+	le := l.lease
+	if le.timer != nil {
+		le.timer.Stop()
+		le.timer = nil
+	}
+
+	if le.units == 0 {
+		return nil
+	}
+
+	if err := l.RequestCancelled(le.units); err != nil {
+		return err
+	}
+
+	le.units = 0
+	return nil
+}
+
+//releaseLease returns the unused units of the lease, if the Limiter has one.
+func (l *Limiter) releaseLease() error {
+	if l.lease == nil {
+		return nil
+	}
+
+	l.lease.mu.Lock()
+	defer l.lease.mu.Unlock()
+
+	return l.returnLease()
+}
+
+//isCoolingDown returns true if the cached status of any level shows a cooldown or ban that has not
+//ended yet, in which case redis decides instead of the lease.
+func (l *Limiter) isCoolingDown() bool {
+	levels := l.levels()
+	unlock := lockLevels(levels)
+	defer unlock()
+
+	now := getUnixTimeMilliseconds()
+	for _, level := range levels {
+		if now < level.status.banUntil || now-level.status.lastErrorTime < level.config.waitAfterHitLimit {
+			return true
+		}
+	}
+
+	return false
+}
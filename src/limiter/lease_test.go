@@ -0,0 +1,170 @@
+package limiter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mediocregopher/radix/v3"
+)
+
+func getPendingRequests(t *testing.T, host string) int {
+	var pending int
+	if err := pool.Do(radix.Cmd(&pending, "HGET", "status:"+host, pendingRequests)); err != nil {
+		t.Fatal(err)
+	}
+
+	return pending
+}
+
+func Test_CanMakeRequestWithLease(t *testing.T) {
+	deleteTestHosts(t, "leaseHost")
+
+	limiter, err := NewLimiter(newTestConfig("leaseHost", 10), pool, WithLease(4, time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type TestLease struct {
+		requestWeight int
+		canMake       bool
+		pending       int //pending requests in redis afterwards
+	}
+
+	testCases := []TestLease{
+		{1, true, 4},   //reserves a lease of 4
+		{1, true, 4},   //taken from the lease
+		{2, true, 4},   //uses up the lease
+		{3, true, 8},   //reserves a new lease of 4
+		{2, true, 9},   //returns the unused unit and only the request fits
+		{1, true, 10},  //uses the last unit of the limit
+		{1, false, 10}, //no capacity left for another lease or the request
+	}
+
+	for i := 0; i < len(testCases); i++ {
+		canMake, _, err := limiter.CanMakeRequest(testCases[i].requestWeight)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if canMake != testCases[i].canMake {
+			t.Errorf("Loop: %v. Expected %v, got: %v", i, testCases[i].canMake, canMake)
+		}
+
+		if pending := getPendingRequests(t, "leaseHost"); pending != testCases[i].pending {
+			t.Errorf("Loop: %v. Expected %v pending requests, got: %v", i, testCases[i].pending, pending)
+		}
+	}
+}
+
+func Test_CloseReturnsLease(t *testing.T) {
+	deleteTestHosts(t, "leaseHost2")
+
+	limiter, err := NewLimiter(newTestConfig("leaseHost2", 10), pool, WithLease(5, time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if canMake, _, err := limiter.CanMakeRequest(2); err != nil || !canMake {
+		t.Fatalf("Expected the request to be allowed, got: %v, %v", canMake, err)
+	}
+
+	if err := limiter.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if pending := getPendingRequests(t, "leaseHost2"); pending != 2 {
+		t.Errorf("Expected the 3 unused units to be returned, got %v pending requests", pending)
+	}
+}
+
+func Test_HitRateLimitReturnsLease(t *testing.T) {
+	deleteTestHosts(t, "leaseHost3")
+
+	config := newTestConfig("leaseHost3", 10)
+	config.waitAfterHitLimit = 60000
+
+	limiter, err := NewLimiter(config, pool, WithLease(5, time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if canMake, _, err := limiter.CanMakeRequest(1); err != nil || !canMake {
+		t.Fatalf("Expected the request to be allowed, got: %v, %v", canMake, err)
+	}
+
+	if err := limiter.HitRateLimit(1); err != nil {
+		t.Fatal(err)
+	}
+
+	if pending := getPendingRequests(t, "leaseHost3"); pending != 0 {
+		t.Errorf("Expected the 4 unused units to be returned, got %v pending requests", pending)
+	}
+
+	//the lease is not used during the cooldown
+	if canMake, _, err := limiter.CanMakeRequest(1); err != nil || canMake {
+		t.Errorf("Expected the request to be denied during the cooldown, got: %v, %v", canMake, err)
+	}
+}
+
+func Test_ExpiredLeaseIsReturned(t *testing.T) {
+	deleteTestHosts(t, "leaseHost4")
+
+	limiter, err := NewLimiter(newTestConfig("leaseHost4", 10), pool, WithLease(5, 50*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if canMake, _, err := limiter.CanMakeRequest(1); err != nil || !canMake {
+		t.Fatalf("Expected the request to be allowed, got: %v, %v", canMake, err)
+	}
+
+	time.Sleep(200 * time.Millisecond)
+
+	if pending := getPendingRequests(t, "leaseHost4"); pending != 1 {
+		t.Errorf("Expected the 4 unused units to be returned once the lease expired, got %v pending requests", pending)
+	}
+}
+
+func Test_LeaseKeptForItsOptions(t *testing.T) {
+	deleteTestHosts(t, "leaseHost5")
+
+	config := newTestConfig("leaseHost5", 10)
+	config.SetPriorityReserve(PriorityHigh, 0.2)
+
+	limiter, err := NewLimiter(config, pool, WithLease(3, time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type TestLeaseOptions struct {
+		opts    []AcquireOption
+		canMake bool
+		pending int //pending requests in redis afterwards
+	}
+
+	testCases := []TestLeaseOptions{
+		{[]AcquireOption{WithPriority(PriorityHigh)}, true, 3}, //reserves a lease of 3
+		{nil, true, 4}, //normal requests are decided by redis
+		{[]AcquireOption{WithPriority(PriorityLow)}, true, 5},
+		{[]AcquireOption{WithConsumer("backfill", 1), WithPriority(PriorityHigh)}, true, 6},
+		{[]AcquireOption{WithPriority(PriorityHigh)}, true, 6}, //taken from the lease
+		{nil, true, 7},
+		{nil, true, 8},  //uses the last request outside of the high reserve
+		{nil, false, 8}, //the unit left in the lease is not handed to a normal request
+	}
+
+	for i := 0; i < len(testCases); i++ {
+		canMake, _, err := limiter.CanMakeRequest(1, testCases[i].opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if canMake != testCases[i].canMake {
+			t.Errorf("Loop: %v. Expected %v, got: %v", i, testCases[i].canMake, canMake)
+		}
+
+		if pending := getPendingRequests(t, "leaseHost5"); pending != testCases[i].pending {
+			t.Errorf("Loop: %v. Expected %v pending requests, got: %v", i, testCases[i].pending, pending)
+		}
+	}
+}
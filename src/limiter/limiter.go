@@ -1,6 +1,10 @@
 package limiter
 
 import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/mediocregopher/radix/v3"
@@ -14,25 +18,76 @@ import (
 //If an api's ratelimit allows 10 requests per second and a specific type of request
 //counts for two of the 10 allowed requests per second, the request weight is two.
 //However, in most cases the request weight is one.
+//
+//A Limiter is safe for concurrent use by multiple goroutines and should be shared by pointer.
 type Limiter struct {
-	status RequestsStatus
-	config RateLimitConfig
-	pool   *radix.Pool
+	mu          sync.Mutex //guards the cached status, config and consumers
+	status      RequestsStatus
+	config      RateLimitConfig
+	base        RateLimitConfig //config the limiter was created with, restored by ResetConfig
+	pool        *radix.Pool
+	parent      *Limiter
+	consumers   map[string]consumerShare
+	queue       bool //admits waiters in the order they arrived
+	notifier    *notifier
+	waitPolicy  WaitPolicy
+	lease       *lease
+	snapshot    *Snapshot //seeds a missing config of the host
+	host        string    //host of the config, kept apart so keys can be built without the lock
+	prefix      string    //namespace of every redis key of the limiter
+	keyTTL      int64     //time in milliseconds the keys of the host live unused, 0 if they never expire
+	probeGrants int       //weight granted as probes that has not completed yet
+	probeEpoch  int64     //lastErrorTime of the cooldown the probes were granted after
 }
 
 //NewLimiter returns a new Limiter and requires a radix pool to allow the limiter
 //to connect to the redis database. It also requires a RateLimitConfig so it can
 //throttle requests to stay under the ratelimit while allowing as many requests as possible.
-func NewLimiter(config RateLimitConfig, pool *radix.Pool) (Limiter, error) {
-	limiter := Limiter{
-		newRequestsStatus(0, 0, 0, 0),
-		config,
-		pool,
+//
+//NewLimiter returns an error if the RateLimitConfig is not valid.
+func NewLimiter(config RateLimitConfig, pool *radix.Pool, opts ...Option) (*Limiter, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	limiter := &Limiter{
+		status: newRequestsStatus(0, 0, 0, 0),
+		config: config,
+		base:   config,
+		pool:   pool,
+		host:   config.host,
+	}
+	//a config in the database without a version has version 0, so it is still loaded on the first decision
+	limiter.config.version = -1
+
+	for _, opt := range opts {
+		opt(limiter)
+	}
+
+	if err := limiter.validateLevels(); err != nil {
+		return nil, err
+	}
+
+	if err := validateKeyPrefix(limiter.prefix); err != nil {
+		return nil, err
+	}
+
+	//a snapshot of another environment must not seed the configs of this one
+	if limiter.snapshot != nil && limiter.snapshot.prefix != limiter.prefix {
+		return nil, fmt.Errorf("snapshot is for key prefix %q, but the limiter uses key prefix %q", limiter.snapshot.prefix, limiter.prefix)
 	}
 
 	statusKey := limiter.getStatusKey()
 	configKey := limiter.getConfigKey()
 
+	var seed map[string]string
+	if limiter.snapshot != nil {
+		var err error
+		if seed, err = limiter.snapshot.config(config.host); err != nil {
+			return nil, err
+		}
+	}
+
 	err := pool.Do(radix.WithConn(statusKey, func(c radix.Conn) error {
 		//must be done before the multi call
 		doesStatusExist, err := limiter.doesHashKeyExist(c, statusKey)
@@ -40,7 +95,7 @@ func NewLimiter(config RateLimitConfig, pool *radix.Pool) (Limiter, error) {
 			return err
 		}
 
-		doesConfigExist, err := limiter.doesHashKeyExist(c, statusKey)
+		doesConfigExist, err := limiter.doesHashKeyExist(c, configKey)
 		if err != nil {
 			return err
 		}
@@ -63,7 +118,8 @@ func NewLimiter(config RateLimitConfig, pool *radix.Pool) (Limiter, error) {
 				requests, 0,
 				pendingRequests, 0,
 				firstRequest, 0,
-				lastErrorTime, 1, //set to one so a new limiter will update config on first CanMakeRequest
+				lastErrorTime, 0,
+				created, getUnixTimeMilliseconds(),
 			))
 
 			if err != nil {
@@ -71,20 +127,26 @@ func NewLimiter(config RateLimitConfig, pool *radix.Pool) (Limiter, error) {
 			}
 		}
 
-		if !doesConfigExist {
-			//if the config key does not exist, save the current config to the database
-			err = c.Do(radix.FlatCmd(nil, "HSET",
-				configKey,
-				limit, config.requestLimit,
-				timePeriod, config.timePeriod,
-				timeBetweenRequests, config.timeBetweenRequests,
-			))
+		if !doesConfigExist && seed != nil {
+			//the config learned before redis lost it is loaded on the first decision
+			if err = c.Do(radix.FlatCmd(nil, "HSET", configKey, seed)); err != nil {
+				return err
+			}
 
-			if err != nil {
+			if err = c.Do(radix.FlatCmd(nil, "HINCRBY", configKey, configVersion, 1)); err != nil {
+				return err
+			}
+		} else if !doesConfigExist {
+			//if the config key does not exist, save the current config to the database
+			if err = limiter.saveConfig(c); err != nil {
 				return err
 			}
 		}
 
+		if err = limiter.refreshKeys(c); err != nil {
+			return err
+		}
+
 		if err = c.Do(radix.Cmd(nil, "EXEC")); err != nil {
 			return err
 		}
@@ -93,7 +155,13 @@ func NewLimiter(config RateLimitConfig, pool *radix.Pool) (Limiter, error) {
 	}))
 
 	if err != nil {
-		return Limiter{}, err
+		return nil, err
+	}
+
+	if limiter.notifier != nil {
+		if err := limiter.subscribe(); err != nil {
+			return nil, err
+		}
 	}
 
 	return limiter, nil
@@ -101,8 +169,22 @@ func NewLimiter(config RateLimitConfig, pool *radix.Pool) (Limiter, error) {
 
 //RequestSuccessful must be called only after CanMakeRequest returned true and
 //when a request has been completed and returned without a 429 or 419 status code
-func (l *Limiter) RequestSuccessful(requestWeight int) error {
+//
+//While a host is recovering from a cooldown, a request that was granted as a probe counts as a successful
+//probe. The Grant of the request, given WithGrant, tells which requests were probes.
+//If the config of the Limiter has a Discovery, a successful request may raise the request limit.
+func (l *Limiter) RequestSuccessful(requestWeight int, opts ...AcquireOption) error {
 	key := l.getStatusKey()
+	levels := l.levels()
+	options := newAcquireOptions(opts)
+
+	//the request was a probe if the level granted it as one
+	unlock := lockLevels(levels)
+	probe := make([]bool, len(levels))
+	for i, level := range levels {
+		probe[i] = level.takeProbe(requestWeight, options.grant)
+	}
+	unlock()
 
 	err := l.pool.Do(radix.WithConn(key, func(c radix.Conn) error {
 		if err := c.Do(radix.Cmd(nil, "MULTI")); err != nil {
@@ -119,8 +201,31 @@ func (l *Limiter) RequestSuccessful(requestWeight int) error {
 			}
 		}()
 
-		if err = l.requestFinished(requestWeight, c, key); err != nil {
-			return err
+		for i, level := range levels {
+			if err = l.requestFinished(requestWeight, c, level.getStatusKey()); err != nil {
+				return err
+			}
+
+			if err = level.refreshKeys(c); err != nil {
+				return err
+			}
+
+			if !probe[i] {
+				continue
+			}
+
+			if err = c.Do(radix.FlatCmd(nil, "HINCRBY", level.getStatusKey(), probes, -requestWeight)); err != nil {
+				return err
+			}
+
+			if err = c.Do(radix.FlatCmd(nil, "HINCRBY", level.getStatusKey(), probing, -requestWeight)); err != nil {
+				return err
+			}
+
+			//waiters can try again, either as the next probe or because the host has recovered
+			if err = level.publish(c, eventReleased); err != nil {
+				return err
+			}
 		}
 
 		if err := c.Do(radix.Cmd(nil, "EXEC")); err != nil {
@@ -134,17 +239,76 @@ func (l *Limiter) RequestSuccessful(requestWeight int) error {
 		return err
 	}
 
-	return nil
+	return l.discover()
 }
 
 //HitRateLimit must be called only after CanMakeRequest returned true and a request
-//has been completed with a status code of 429 or 419. This will automatically adjust
+//has been completed with a status code of 429. This will automatically adjust
 //the RateLimitConfig in the Limiter struct to prevent more 429s in the future.
+//
+//If the Limiter has a parent, the config of the level that has used the largest share
+//of its request limit is the one that gets adjusted.
 func (l *Limiter) HitRateLimit(requestWeight int) error {
+	return l.hitLimit(requestWeight, func(limited *Limiter, c radix.Conn) error {
+		return limited.adjustConfig(requestWeight, c)
+	})
+}
+
+//hitLimit finishes the request on every level and lets adjust change the level that most
+//likely caused the error, all in one transaction.
+func (l *Limiter) hitLimit(requestWeight int, adjust func(limited *Limiter, c radix.Conn) error) error {
+	//the lease is given back and kept locked, so no units are handed out until the cooldown is saved
+	if l.lease != nil {
+		l.lease.mu.Lock()
+		defer l.lease.mu.Unlock()
+
+		if err := l.returnLease(); err != nil {
+			return err
+		}
+	}
+
+	unlock := lockLevels(l.levels())
+	defer unlock()
+
+	for {
+		aborted, err := l.tryHitLimit(requestWeight, adjust)
+		if err != nil || !aborted {
+			return err
+		}
+	}
+}
 
-	statusKey := l.getStatusKey()
+//tryHitLimit makes one attempt at finishing the request and adjusting the limited level. aborted is true
+//when another process changed the config of a level first, so the adjustment is made from the config it saved.
+//The levels must be locked.
+func (l *Limiter) tryHitLimit(requestWeight int, adjust func(limited *Limiter, c radix.Conn) error) (bool, error) {
+	var resp []string
+
+	err := l.pool.Do(radix.WithConn(l.getStatusKey(), func(c radix.Conn) error {
+		configKeys := make([]string, 0, len(l.levels()))
+		for _, level := range l.levels() {
+			configKeys = append(configKeys, level.getConfigKey())
+		}
+
+		if err := c.Do(radix.Cmd(nil, "WATCH", configKeys...)); err != nil {
+			return err
+		}
+
+		//another process may have updated or adjusted the configs since the last decision
+		for _, level := range l.levels() {
+			if err := level.config.updateConfigFromDatabase(c, level.getConfigKey()); err != nil {
+				c.Do(radix.Cmd(nil, "UNWATCH"))
+				return err
+			}
+		}
+
+		//must be done before the multi call
+		limited, err := l.limitedLevel(c)
+		if err != nil {
+			c.Do(radix.Cmd(nil, "UNWATCH"))
+			return err
+		}
 
-	err := l.pool.Do(radix.WithConn(statusKey, func(c radix.Conn) error {
 		if err := c.Do(radix.Cmd(nil, "MULTI")); err != nil {
 			return err
 		}
@@ -152,33 +316,38 @@ func (l *Limiter) HitRateLimit(requestWeight int) error {
 		// the transaction is discarded. This isn't strictly necessary if the
 		// error was a network error, as the connection would be closed by the
 		// client anyway, but it's important otherwise.
-		var err error
 		defer func() {
 			if err != nil {
 				c.Do(radix.Cmd(nil, "DISCARD"))
 			}
 		}()
 
-		if err = l.requestFinished(requestWeight, c, statusKey); err != nil {
-			return err
+		for _, level := range l.levels() {
+			if err = l.requestFinished(requestWeight, c, level.getStatusKey()); err != nil {
+				return err
+			}
+
+			if err = level.refreshKeys(c); err != nil {
+				return err
+			}
 		}
 
-		if err = l.adjustConfig(requestWeight, c); err != nil {
+		if err = adjust(limited, c); err != nil {
 			return err
 		}
 
-		if err := c.Do(radix.Cmd(nil, "EXEC")); err != nil {
+		if err = limited.publish(c, eventConfig); err != nil {
 			return err
 		}
 
-		return nil
+		return c.Do(radix.Cmd(&resp, "EXEC"))
 	}))
-
 	if err != nil {
-		return err
+		return false, err
 	}
 
-	return nil
+	//resp is nil if the transaction was aborted
+	return resp == nil, nil
 }
 
 //requestFinished updates the RequestsStatus struct by removing a pending request into the
@@ -197,8 +366,19 @@ func (l *Limiter) requestFinished(requestWeight int, c radix.Conn, key string) e
 
 //RequestCancelled must be called if CanMakeRequest returned true, but the request
 //to the api was never actually made.
-func (l *Limiter) RequestCancelled(requestWeight int) error {
+func (l *Limiter) RequestCancelled(requestWeight int, opts ...AcquireOption) error {
 	key := l.getStatusKey()
+	levels := l.levels()
+	options := newAcquireOptions(opts)
+
+	//a cancelled probe frees its place for another probe, without counting as a successful one
+	unlock := lockLevels(levels)
+	probe := make([]bool, len(levels))
+	for i, level := range levels {
+		probe[i] = level.takeProbe(requestWeight, options.grant)
+	}
+	unlock()
+
 	//this is radix's way of doing a transaction
 	err := l.pool.Do(radix.WithConn(key, func(c radix.Conn) error {
 
@@ -216,8 +396,24 @@ func (l *Limiter) RequestCancelled(requestWeight int) error {
 			}
 		}()
 
-		if err = c.Do(radix.FlatCmd(nil, "HINCRBY", key, pendingRequests, -requestWeight)); err != nil {
-			return err
+		for i, level := range levels {
+			if err = c.Do(radix.FlatCmd(nil, "HINCRBY", level.getStatusKey(), pendingRequests, -requestWeight)); err != nil {
+				return err
+			}
+
+			if probe[i] {
+				if err = c.Do(radix.FlatCmd(nil, "HINCRBY", level.getStatusKey(), probing, -requestWeight)); err != nil {
+					return err
+				}
+			}
+
+			if err = level.refreshKeys(c); err != nil {
+				return err
+			}
+
+			if err = level.publish(c, eventReleased); err != nil {
+				return err
+			}
 		}
 
 		if err = c.Do(radix.Cmd(nil, "EXEC")); err != nil {
@@ -237,38 +433,109 @@ func (l *Limiter) RequestCancelled(requestWeight int) error {
 //CanMakeRequest communicates with the database to figure out when it is possible to
 //make a request. If a request can be made it returns true, 0. If a request cannot be made
 //it returns false and the amount of time to sleep before your program should call CanMakeRequest again
-func (l *Limiter) CanMakeRequest(requestWeight int) (bool, int64) {
-	statusKey := l.getStatusKey()
-	configKey := l.getConfigKey()
-	var canMake bool
+//
+//An error is returned if the database cannot be reached or if the request weight can never
+//fit into the request limit, in which case waiting would never allow the request.
+//
+//AcquireOptions such as WithPriority change how the request is acquired.
+//
+//If the Limiter was created WithLease, the request is taken from the local lease when it fits.
+func (l *Limiter) CanMakeRequest(requestWeight int, opts ...AcquireOption) (bool, time.Duration, error) {
+	options := newAcquireOptions(opts)
+
+	if l.lease != nil {
+		return l.acquireFromLease(requestWeight, options)
+	}
+
+	return l.reserveLevels(requestWeight, options)
+}
+
+//reserveLevels reserves the request weight on the limiter and all of its parents
+func (l *Limiter) reserveLevels(requestWeight int, options acquireOptions) (bool, time.Duration, error) {
+	granted, wait, err := reserve(context.Background(), l.pool, l.levels(), l.levelWeights(requestWeight), 1, options)
+	return granted == 1, wait, err
+}
+
+//levelWeights returns the request weight for every level of the limiter
+func (l *Limiter) levelWeights(requestWeight int) []int {
+	weights := make([]int, len(l.levels()))
+	for i := range weights {
+		weights[i] = requestWeight
+	}
+
+	return weights
+}
+
+//reserve checks how many of count requests every limiter can make with its weight and adds the pending
+//requests of as many as all of them allow in a single transaction. If not all of the requests can be made,
+//the longest wait time of the limiters before the next one can be made is returned.
+//
+//A transaction that was aborted by another process is tried again right away, unless the context is done.
+func reserve(ctx context.Context, pool *radix.Pool, limiters []*Limiter, weights []int, count int, options acquireOptions) (int, time.Duration, error) {
+	for {
+		if err := ctx.Err(); err != nil {
+			return 0, 0, err
+		}
+
+		unlock := lockLevels(limiters)
+		granted, wait, aborted, err := tryReserve(pool, limiters, weights, count, options)
+		unlock()
+
+		if err != nil {
+			return 0, millisecondsToDuration(wait), err
+		}
+
+		if aborted && wait == 0 {
+			continue
+		}
+
+		return granted, millisecondsToDuration(wait), nil
+	}
+}
+
+//tryReserve makes one attempt at reserving the requests. aborted is true when nothing was reserved,
+//either because no request could be made or because another process changed a watched key.
+//The limiters must be locked.
+func tryReserve(pool *radix.Pool, limiters []*Limiter, weights []int, count int, options acquireOptions) (int, int64, bool, error) {
+	var granted int
 	var wait int64
 	var resp []string
+	//the probes granted by this attempt are recorded once the transaction has succeeded
+	probingBefore := make([]int, len(limiters))
 
-	err := l.pool.Do(radix.WithConn(statusKey, func(c radix.Conn) error {
-		if err := c.Do(radix.Cmd(nil, "WATCH", statusKey, configKey)); err != nil {
+	err := pool.Do(radix.WithConn(limiters[0].getStatusKey(), func(c radix.Conn) error {
+		if err := c.Do(radix.Cmd(nil, "WATCH", watchedKeys(limiters, options)...)); err != nil {
 			return err
 		}
 
-		lastError := l.status.lastErrorTime
+		var err error
+		for _, l := range limiters {
+			if err = l.loadState(c, options); err != nil {
+				break
+			}
+		}
 
-		if err := l.status.updateStatusFromDatabase(c, statusKey); err != nil {
-			return err
+		for i, l := range limiters {
+			probingBefore[i] = l.status.probing
 		}
-		//only updates config from database when the lastErrorTime changes
-		if lastError != l.status.lastErrorTime {
-			if err := l.config.updateConfigFromDatabase(c, configKey); err != nil {
-				return err
-			}
+
+		if err == nil {
+			granted, wait, err = grantRequests(limiters, weights, count, options)
 		}
 
-		canMake, wait = l.status.canMakeRequestLogic(requestWeight, l.config)
+		if err != nil {
+			//err doesn't matter. any error is a network err, so client will close conn.
+			c.Do(radix.Cmd(nil, "UNWATCH"))
+			return err
+		}
 
-		if !canMake {
-			err := c.Do(radix.Cmd(nil, "UNWATCH"))
-			if err != nil {
-				return err
+		if granted == 0 {
+			if options.consumer == "" {
+				return c.Do(radix.Cmd(nil, "UNWATCH"))
 			}
-			return nil
+
+			//a denied consumer is still active, so its share is kept for it
+			return markConsumerActive(c, limiters, options)
 		}
 
 		if err := c.Do(radix.Cmd(nil, "MULTI")); err != nil {
@@ -279,22 +546,27 @@ func (l *Limiter) CanMakeRequest(requestWeight int) (bool, int64) {
 		// the transaction is discarded. This isn't strictly necessary if the
 		// error was a network error, as the connection would be closed by the
 		// client anyway, but it's important otherwise.
-		var err error
 		defer func() {
 			if err != nil {
 				c.Do(radix.Cmd(nil, "DISCARD"))
 			}
 		}()
 
-		err = c.Do(radix.FlatCmd(nil, "HSET",
-			statusKey,
-			requests, l.status.requests,
-			pendingRequests, l.status.pendingRequests,
-			firstRequest, l.status.firstRequest,
-			lastErrorTime, l.status.lastErrorTime,
-		))
-		if err != nil {
-			return err
+		now := getUnixTimeMilliseconds()
+		for i, l := range limiters {
+			if err = l.saveStatus(c); err != nil {
+				return err
+			}
+
+			if err = l.refreshKeys(c); err != nil {
+				return err
+			}
+
+			if options.consumer != "" {
+				if err = l.saveConsumer(c, options, weights[i]*granted, true, now); err != nil {
+					return err
+				}
+			}
 		}
 
 		if err := c.Do(radix.Cmd(&resp, "EXEC")); err != nil {
@@ -304,55 +576,271 @@ func (l *Limiter) CanMakeRequest(requestWeight int) (bool, int64) {
 		return nil
 	}))
 	if err != nil {
-		return false, wait
+		return 0, wait, false, err
 	}
 	//resp is the response to the EXEC command
 	//if resp is nil the transaction was aborted
 	if resp == nil {
-		if wait == 0 {
-			return l.CanMakeRequest(requestWeight)
+		return 0, wait, true, nil
+	}
+
+	probed := make([]int, len(limiters))
+	for i, l := range limiters {
+		probed[i] = l.status.probing - probingBefore[i]
+		l.recordProbes(probed[i])
+	}
+	recordGrant(options.grant, limiters, probed)
+
+	return granted, wait, false, nil
+}
+
+//grantRequests adds up to count requests to the statuses of the limiters, one request at a time, as long
+//as every limiter allows the next one. It returns how many were granted and, if that is less than count,
+//the longest time any limiter asks to wait before the next request can be made.
+func grantRequests(limiters []*Limiter, weights []int, count int, options acquireOptions) (int, int64, error) {
+	statuses := make([]RequestsStatus, len(limiters))
+	shares := make([]consumerShare, len(limiters))
+
+	if options.consumer != "" {
+		//the shares only count the granted requests while deciding, saveConsumer adds them all at once
+		for i, l := range limiters {
+			shares[i] = l.consumers[options.consumer]
+		}
+
+		defer func() {
+			for i, l := range limiters {
+				l.consumers[options.consumer] = shares[i]
+			}
+		}()
+	}
+
+	for granted := 0; granted < count; granted++ {
+		canMake := true
+		var wait int64
+
+		for i, l := range limiters {
+			statuses[i] = l.status
+
+			limiterCanMake, limiterWait, err := l.checkRequest(weights[i], options)
+			if err != nil {
+				return granted, 0, err
+			}
+
+			if !limiterCanMake {
+				canMake = false
+				if limiterWait > wait {
+					wait = limiterWait
+				}
+			}
+		}
+
+		if !canMake {
+			//the request that was denied is not added to any of the limiters
+			for i, l := range limiters {
+				l.status = statuses[i]
+			}
+
+			//the rest of the requests may need more than the next one
+			if rest := count - granted; rest > 1 {
+				now := getUnixTimeMilliseconds()
+				for i, l := range limiters {
+					if restWait := l.restWait(weights[i], rest, options, now); restWait > wait {
+						wait = restWait
+					}
+				}
+			}
+			return granted, wait, nil
+		}
+
+		if options.consumer != "" {
+			for i, l := range limiters {
+				share := l.consumers[options.consumer]
+				share.used = share.usedIn(l.status.firstRequest) + weights[i]
+				share.period = l.status.firstRequest
+				l.consumers[options.consumer] = share
+			}
 		}
-		return false, wait
 	}
 
-	return canMake, wait
+	return count, 0, nil
+}
+
+//lockLevels locks every limiter in the order of their status keys, so goroutines locking overlapping
+//sets of limiters cannot deadlock. It returns a function that unlocks them again.
+func lockLevels(limiters []*Limiter) func() {
+	sorted := make([]*Limiter, len(limiters))
+	copy(sorted, limiters)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].getStatusKey() < sorted[j].getStatusKey()
+	})
+
+	for _, l := range sorted {
+		l.mu.Lock()
+	}
+
+	return func() {
+		for _, l := range sorted {
+			l.mu.Unlock()
+		}
+	}
 }
 
-//WaitForRatelimit recursively calls CanMakeRequest until a request can be made.
+//WaitForRatelimit calls CanMakeRequest until a request can be made.
 //It handles the sleeping when a request cannot be made and it blocks until
-//a request can be made.
-func (l *Limiter) WaitForRatelimit(requestWeight int) {
-	canMake, sleepTime := l.CanMakeRequest(requestWeight)
-	if canMake {
-		return
+//a request can be made. It returns early with the error if CanMakeRequest errors.
+//
+//The WaitPolicy of the Limiter decides how long to sleep between attempts and how many attempts
+//are made before ErrTooManyAttempts is returned.
+//If the Limiter was created WithQueue, waiters are admitted in the order they arrived.
+//If it was created WithNotifications, waiters try again as soon as capacity is released.
+func (l *Limiter) WaitForRatelimit(requestWeight int, opts ...AcquireOption) error {
+	return l.wait(context.Background(), requestWeight, opts)
+}
+
+//wait blocks until a request can be made or the context is done
+func (l *Limiter) wait(ctx context.Context, requestWeight int, opts []AcquireOption) error {
+	if l.queue {
+		return l.waitInQueue(ctx, requestWeight, opts)
+	}
+
+	for attempt := 1; ; attempt++ {
+		canMake, sleepTime, err := l.CanMakeRequest(requestWeight, opts...)
+		if err != nil {
+			return err
+		}
+		if canMake {
+			return nil
+		}
+
+		sleepTime, err = l.waitPolicy.delay(attempt, sleepTime)
+		if err != nil {
+			return err
+		}
+
+		if err := l.sleep(ctx, sleepTime); err != nil {
+			return err
+		}
+	}
+}
+
+//loadState loads the status of the limiter from the database, as well as its config when the version
+//of the config changed. It must be called after the keys of the limiter have been watched.
+func (l *Limiter) loadState(c radix.Conn, options acquireOptions) error {
+	if options.consumer != "" {
+		if err := l.updateConsumersFromDatabase(c); err != nil {
+			return err
+		}
+	}
+
+	if err := l.status.updateStatusFromDatabase(c, l.getStatusKey()); err != nil {
+		return err
+	}
+
+	version, err := getVersionFromDatabase(c, l.getConfigKey())
+	if err != nil {
+		return err
 	}
-	time.Sleep(time.Duration(sleepTime) * time.Millisecond)
-	l.WaitForRatelimit(requestWeight)
+	//only updates config from database when its version changes
+	if version != l.config.version {
+		if err := l.config.updateConfigFromDatabase(c, l.getConfigKey()); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
-//adjustConfig reduces the number of allowed requests per time period by one and saves
-//the new config to the database updates the lastErrorTime to the current time
-func (l *Limiter) adjustConfig(requestWeight int, c radix.Conn) error {
-	if l.config.requestLimit-requestWeight > 0 {
-		l.config.requestLimit -= requestWeight
-		l.config.setTimeBetweenRequests()
+//checkRequest checks if a request can be made with the loaded state of the limiter.
+//If it can, the pending request is added to the status of the limiter.
+func (l *Limiter) checkRequest(requestWeight int, options acquireOptions) (bool, int64, error) {
+	now := getUnixTimeMilliseconds()
+	decayed := l.config.decayed(now)
+
+	//the limit in the database may have been lowered since the first check
+	fullConfig := decayed.forPriority(options.priority)
+	if err := fullConfig.checkRequestWeight(requestWeight); err != nil {
+		return false, 0, err
 	}
 
-	configKey := l.getConfigKey()
-	statusKey := l.getStatusKey()
+	//a request larger than the warmed up limit waits for the warm-up instead of failing
+	config := decayed.warmedUp(l.status, now).forPriority(options.priority)
 
-	err := c.Do(radix.FlatCmd(nil, "HSET", configKey,
-		limit, l.config.requestLimit,
-		timePeriod, l.config.timePeriod,
-		timeBetweenRequests, l.config.timeBetweenRequests,
+	canMake, wait := l.status.canMakeRequestLogic(requestWeight, config)
+
+	if canMake && options.consumer != "" && config.requestLimit > 0 {
+		//canMakeRequestLogic already added the request to the pending requests
+		used := l.status.requests + l.status.pendingRequests - requestWeight
+
+		if !l.fairShareAllows(options, requestWeight, config.requestLimit, used, l.status.firstRequest, now) {
+			//the shares start over with the next period
+			return false, l.status.timeUntilEndOfPeriod(now, config), nil
+		}
+	}
+
+	return canMake, wait, nil
+}
+
+//saveStatus saves the status of the limiter to the database. It must be called inside of a transaction.
+func (l *Limiter) saveStatus(c radix.Conn) error {
+	return c.Do(radix.FlatCmd(nil, "HSET",
+		l.getStatusKey(),
+		requests, l.status.requests,
+		pendingRequests, l.status.pendingRequests,
+		firstRequest, l.status.firstRequest,
+		lastErrorTime, l.status.lastErrorTime,
+		probing, l.status.probing,
 	))
+}
 
-	if err != nil {
+//watchedKeys returns the status and config keys of the limiters, which must be watched
+//while deciding if a request can be made. The consumers keys are watched for requests that take
+//part in fair sharing.
+func watchedKeys(limiters []*Limiter, options acquireOptions) []string {
+	keys := make([]string, 0, len(limiters)*3)
+	for _, l := range limiters {
+		keys = append(keys, l.getStatusKey(), l.getConfigKey())
+		if options.consumer != "" {
+			keys = append(keys, l.getConsumersKey())
+		}
+	}
+
+	return keys
+}
+
+//adjustConfig reduces the number of allowed requests per time period by the reduction, no lower than the
+//bounds of the config, and saves the new config to the database updates the lastErrorTime to the current time
+func (l *Limiter) adjustConfig(reduction int, c radix.Conn) error {
+	now := getUnixTimeMilliseconds()
+	//the reduction is made from the limit as it is now, including what has already worn off
+	l.config = l.config.decayed(now)
+
+	//a limit raised by discovery goes back to the last rate that was free of errors instead
+	if !l.config.settleDiscovery() {
+		//a limit of 0 is an infinite rate, which has nothing to reduce
+		if l.config.requestLimit > 0 {
+			limit := l.config.requestLimit - reduction
+			if limit < 1 {
+				limit = 1
+			}
+
+			l.config.requestLimit = l.config.boundLimit(limit)
+			l.config.setTimeBetweenRequests()
+		}
+		l.config.reducedAt = now
+	}
+
+	if err := l.saveConfig(c); err != nil {
 		return err
 	}
 
-	err = c.Do(radix.FlatCmd(nil, "HSET", statusKey,
-		lastErrorTime, getUnixTimeMilliseconds(),
+	l.status.lastErrorTime = now
+	l.status.probes = l.config.recoveryProbes
+	l.status.probing = 0
+
+	err := c.Do(radix.FlatCmd(nil, "HSET", l.getStatusKey(),
+		lastErrorTime, now,
+		probes, l.config.recoveryProbes,
+		probing, 0,
 	))
 
 	if err != nil {
@@ -362,19 +850,123 @@ func (l *Limiter) adjustConfig(requestWeight int, c radix.Conn) error {
 	return nil
 }
 
+//saveConfig saves the config of the limiter to the database and increments its version, so every
+//limiter using the host loads the new config on its next decision. It must be called inside of a transaction.
+func (l *Limiter) saveConfig(c radix.Conn) error {
+	configSettings, err := l.config.settingsToDatabase()
+	if err != nil {
+		return err
+	}
+
+	//the base limit is shared, so only a host that has none yet is given the one of this limiter
+	if l.config.baseLimit == 0 {
+		l.config.baseLimit = l.base.requestLimit
+	}
+
+	err = c.Do(radix.FlatCmd(nil, "HSET", l.getConfigKey(),
+		limit, l.config.requestLimit,
+		timePeriodMs, l.config.timePeriod,
+		timeBetweenRequests, l.config.timeBetweenRequests,
+		baseLimit, l.config.baseLimit,
+		reducedAt, l.config.reducedAt,
+		stableLimit, l.config.stableLimit,
+		raisedAt, l.config.raisedAt,
+		settled, l.config.settled,
+		settings, configSettings,
+	))
+
+	if err != nil {
+		return err
+	}
+
+	return c.Do(radix.FlatCmd(nil, "HINCRBY", l.getConfigKey(), configVersion, 1))
+}
+
+//UpdateConfig replaces the config of the limiter and saves it, including every setting such as the
+//burst mode, to the database, where every limiter using the host sees the change within one decision.
+//Limiters created WithNotifications are woken up so they can use the new config right away.
+//
+//UpdateConfig returns an error if the config is not valid or is for a different host.
+func (l *Limiter) UpdateConfig(config RateLimitConfig) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+
+	if config.host != l.host {
+		return fmt.Errorf("config is for host %q, but the limiter is for host %q", config.host, l.host)
+	}
+
+	key := l.getConfigKey()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.pool.Do(radix.WithConn(key, func(c radix.Conn) error {
+		if err := c.Do(radix.Cmd(nil, "MULTI")); err != nil {
+			return err
+		}
+
+		var err error
+		defer func() {
+			if err != nil {
+				c.Do(radix.Cmd(nil, "DISCARD"))
+			}
+		}()
+
+		//the new config is the base every adjustment of the host starts from
+		config.baseLimit = config.requestLimit
+		l.config = config
+		l.base = config
+		if err = l.saveConfig(c); err != nil {
+			return err
+		}
+
+		if err = l.publish(c, eventConfig); err != nil {
+			return err
+		}
+
+		return c.Do(radix.Cmd(nil, "EXEC"))
+	}))
+}
+
 //GetStatus returns the status of the requests, which includes the number on requests
 //made in the period, the number of pending requests, the timestamp of the beginning
 //of the period, and the timestamp for when the last error occurred.
+//
+//The status is a consistent snapshot of what the limiter saw on its last decision.
 func (l *Limiter) GetStatus() RequestsStatus {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
 	return l.status
 }
 
 func (l *Limiter) getStatusKey() string {
-	return "status:" + l.config.host
+	return formatKey(l.prefix, statusKind, l.host)
 }
 
 func (l *Limiter) getConfigKey() string {
-	return "config:" + l.config.host
+	return formatKey(l.prefix, configKind, l.host)
+}
+
+func (l *Limiter) getConsumersKey() string {
+	return formatKey(l.prefix, consumersKind, l.host)
+}
+
+func (l *Limiter) getQueueKey() string {
+	return formatKey(l.prefix, queueKind, l.host)
+}
+
+func (l *Limiter) getTicketsKey() string {
+	return formatKey(l.prefix, ticketsKind, l.host)
+}
+
+func (l *Limiter) getHeartbeatsKey() string {
+	return formatKey(l.prefix, heartbeatsKind, l.host)
+}
+
+func (l *Limiter) getEventsChannel() string {
+	return formatKey(l.prefix, eventsKind, l.host)
 }
 
 func (l *Limiter) doesHashKeyExist(c radix.Conn, key string) (bool, error) {
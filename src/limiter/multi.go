@@ -0,0 +1,57 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+//MultiAcquire makes a request on several limiters at once. The requests are either reserved on all of
+//the limiters, including their parents, or on none of them, in a single transaction. If the requests
+//can be made it returns true, 0. If they cannot, it returns false and the longest time any of the
+//limiters asks to wait before MultiAcquire should be called again.
+//
+//weights[i] is the request weight for limiters[i]. Once the requests have been made, RequestSuccessful,
+//HitRateLimit or RequestCancelled must be called on each limiter with its weight, just as if its
+//CanMakeRequest had returned true. All of the limiters must use the same redis database.
+//The AcquireOptions apply to the requests on every limiter.
+//
+//	canMake, wait, err := MultiAcquire([]*Limiter{priceLimiter, orderLimiter}, []int{1, 1})
+func MultiAcquire(limiters []*Limiter, weights []int, opts ...AcquireOption) (bool, time.Duration, error) {
+	if len(limiters) != len(weights) {
+		return false, 0, errors.New("MultiAcquire needs exactly one request weight per limiter")
+	}
+
+	if len(limiters) == 0 {
+		return true, 0, nil
+	}
+
+	levels, levelWeights := combineLevels(limiters, weights)
+
+	granted, wait, err := reserve(context.Background(), limiters[0].pool, levels, levelWeights, 1, newAcquireOptions(opts))
+	return granted == 1, wait, err
+}
+
+//combineLevels returns every level of the given limiters once. When several limiters share a level,
+//such as two children of the same parent, their weights are added together for that level.
+func combineLevels(limiters []*Limiter, weights []int) ([]*Limiter, []int) {
+	var levels []*Limiter
+	var levelWeights []int
+	indexes := make(map[string]int)
+
+	for i, l := range limiters {
+		for _, level := range l.levels() {
+			key := level.getStatusKey()
+			if index, ok := indexes[key]; ok {
+				levelWeights[index] += weights[i]
+				continue
+			}
+
+			indexes[key] = len(levels)
+			levels = append(levels, level)
+			levelWeights = append(levelWeights, weights[i])
+		}
+	}
+
+	return levels, levelWeights
+}
@@ -0,0 +1,69 @@
+package limiter
+
+import "testing"
+
+func Test_MultiAcquire(t *testing.T) {
+	deleteTestHosts(t, "multiPrices", "multiOrders")
+
+	prices, err := NewLimiter(newTestConfig("multiPrices", 2), pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	orders, err := NewLimiter(newTestConfig("multiOrders", 1), pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	limiters := []*Limiter{prices, orders}
+
+	canMake, _, err := MultiAcquire(limiters, []int{1, 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !canMake {
+		t.Errorf("Expected the first requests to be reserved on both limiters")
+	}
+
+	//orders is full, so prices must not be reserved either
+	canMake, wait, err := MultiAcquire(limiters, []int{1, 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if canMake || wait <= 0 {
+		t.Errorf("Expected the requests to be denied with a wait time, got: %v, %v", canMake, wait)
+	}
+
+	canMake, _, err = prices.CanMakeRequest(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !canMake {
+		t.Errorf("Expected the denied MultiAcquire to leave the capacity of prices untouched")
+	}
+
+	if _, _, err := MultiAcquire(limiters, []int{1}); err == nil {
+		t.Errorf("Expected an error when the number of weights does not match the number of limiters")
+	}
+}
+
+func Test_CombineLevels(t *testing.T) {
+	account := Limiter{host: "combineAccount", config: NewRateLimitConfig("combineAccount", 10, 1, 10, 1, 0)}
+	orders := Limiter{host: "combineOrders", config: NewRateLimitConfig("combineOrders", 5, 1, 5, 1, 0), parent: &account}
+	data := Limiter{host: "combineData", config: NewRateLimitConfig("combineData", 5, 1, 5, 1, 0), parent: &account}
+
+	levels, weights := combineLevels([]*Limiter{&orders, &data}, []int{2, 3})
+
+	expectedLevels := []*Limiter{&orders, &account, &data}
+	expectedWeights := []int{2, 5, 3}
+
+	if len(levels) != len(expectedLevels) {
+		t.Fatalf("Expected %v levels, got: %v", len(expectedLevels), len(levels))
+	}
+
+	for i := range levels {
+		if levels[i] != expectedLevels[i] || weights[i] != expectedWeights[i] {
+			t.Errorf("Loop: %v. Expected %v with weight %v, got: %v with weight %v", i, expectedLevels[i].config.host, expectedWeights[i], levels[i].config.host, weights[i])
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package limiter
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/mediocregopher/radix/v3"
+)
+
+const (
+	//eventReleased is published when pending requests are cancelled and their capacity can be used again
+	eventReleased = "released"
+	//eventConfig is published when the config of a host changes
+	eventConfig = "config"
+)
+
+//notifier wakes up every waiter of a Limiter when an event is published on the channel of one of its hosts.
+type notifier struct {
+	pubsub   radix.PubSubConn
+	messages chan radix.PubSubMessage
+	channels []string
+
+	mu   sync.Mutex
+	wake chan struct{} //closed and replaced every time an event arrives
+}
+
+//WithNotifications lets waiters wake up as soon as capacity is released or the config of the host changes,
+//instead of sleeping for the whole wait time. The Limiter subscribes to the events of its host, and of its
+//parents, using the given PubSubConn, which must be connected to the same redis database as the pool.
+//
+//	limiter, err := NewLimiter(config, pool, WithNotifications(radix.PersistentPubSub("tcp", "127.0.0.1:6379", nil)))
+//Events are published by every Limiter, whether it was created with WithNotifications or not.
+//Close must be called once the Limiter is no longer used to unsubscribe from the events.
+func WithNotifications(ps radix.PubSubConn) Option {
+	return func(l *Limiter) {
+		l.notifier = &notifier{
+			pubsub: ps,
+			wake:   make(chan struct{}),
+		}
+	}
+}
+
+//subscribe starts listening for the events of every level of the limiter
+func (l *Limiter) subscribe() error {
+	n := l.notifier
+	n.messages = make(chan radix.PubSubMessage, 16)
+	for _, level := range l.levels() {
+		n.channels = append(n.channels, level.getEventsChannel())
+	}
+
+	if err := n.pubsub.Subscribe(n.messages, n.channels...); err != nil {
+		return err
+	}
+
+	go n.listen()
+	return nil
+}
+
+//listen wakes up every waiter whenever an event arrives, until the subscription is closed.
+func (n *notifier) listen() {
+	for range n.messages {
+		n.mu.Lock()
+		close(n.wake)
+		n.wake = make(chan struct{})
+		n.mu.Unlock()
+	}
+}
+
+//sleep blocks for the given duration, until the context is done, or until an event is published
+//for the host if the Limiter was created WithNotifications. It returns the error of the context if it is done.
+func (l *Limiter) sleep(ctx context.Context, d time.Duration) error {
+	//a nil channel never wakes up the select
+	var wake chan struct{}
+	if l.notifier != nil {
+		l.notifier.mu.Lock()
+		wake = l.notifier.wake
+		l.notifier.mu.Unlock()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case <-wake:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	return nil
+}
+
+//Close returns the unused units of the lease of the Limiter and unsubscribes it from the events
+//of its host. It does nothing if the Limiter was created without WithLease or WithNotifications.
+//The PubSubConn itself is not closed.
+func (l *Limiter) Close() error {
+	if err := l.releaseLease(); err != nil {
+		return err
+	}
+
+	n := l.notifier
+	if n == nil || n.messages == nil {
+		return nil
+	}
+
+	if err := n.pubsub.Unsubscribe(n.messages, n.channels...); err != nil {
+		return err
+	}
+
+	close(n.messages)
+	n.messages = nil
+	return nil
+}
+
+//publish sends an event to everyone listening on the channel of the host. It is meant to be called
+//inside of a transaction, so the event is only sent if the transaction succeeds.
+func (l *Limiter) publish(c radix.Conn, event string) error {
+	return c.Do(radix.Cmd(nil, "PUBLISH", l.getEventsChannel(), event))
+}
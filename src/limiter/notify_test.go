@@ -0,0 +1,62 @@
+package limiter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mediocregopher/radix/v3"
+)
+
+func Test_WaitForRatelimitWakesOnRelease(t *testing.T) {
+	deleteTestHosts(t, "notifyHost")
+
+	config := NewRateLimitConfigFromWindows("notifyHost", Window{1, 5 * time.Second}, Window{1, 5 * time.Second}, 0)
+	config.SetBurstMode(true)
+
+	holder, err := NewLimiter(config, pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ps := radix.PersistentPubSub("tcp", "127.0.0.1:6379", nil)
+	defer ps.Close()
+
+	waiter, err := NewLimiter(config, pool, WithNotifications(ps))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer waiter.Close()
+
+	if canMake, _, err := holder.CanMakeRequest(1); err != nil || !canMake {
+		t.Fatalf("Expected the first request to be allowed, got: %v, %v", canMake, err)
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- waiter.WaitForRatelimit(1)
+	}()
+
+	//gives the waiter time to be denied and go to sleep
+	time.Sleep(100 * time.Millisecond)
+
+	if err := holder.RequestCancelled(1); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Errorf("Expected the waiter to wake up when the pending request was cancelled")
+	}
+}
+
+func Test_CloseWithoutNotifications(t *testing.T) {
+	limiter := Limiter{host: "test_host_1", config: NewRateLimitConfig("test_host_1", 20, 1, 20, 1, 0)}
+
+	if err := limiter.Close(); err != nil {
+		t.Errorf("Expected closing a limiter without notifications to do nothing, got: %v", err)
+	}
+}
@@ -0,0 +1,94 @@
+package limiter
+
+import "fmt"
+
+//Priority is how important a request is. Part of the request limit can be set aside for
+//requests of a priority, so that less important requests cannot use all of it.
+type Priority int
+
+const (
+	//PriorityLow is meant for background work, such as backfill jobs.
+	PriorityLow Priority = iota
+	//PriorityNormal is the priority of requests that do not ask for one.
+	PriorityNormal
+	//PriorityHigh is meant for requests someone is waiting on, such as user facing calls.
+	PriorityHigh
+
+	numOfPriorities = 3
+)
+
+//WithPriority sets the priority of a request. Requests without a priority are PriorityNormal.
+//A priority above PriorityHigh is treated as PriorityHigh, and one below PriorityLow as PriorityLow.
+//
+//	canMake, sleepTime, err := limiter.CanMakeRequest(1, WithPriority(PriorityHigh))
+func WithPriority(priority Priority) AcquireOption {
+	return func(o *acquireOptions) {
+		o.priority = priority.clamp()
+	}
+}
+
+//SetPriorityReserve sets aside a share of the request limit for requests of the given priority or higher.
+//Requests of a lower priority are denied once they would use any of the reserved requests.
+//
+//	config.SetPriorityReserve(PriorityHigh, 0.2)
+//The config above sets aside 20% of the request limit for PriorityHigh requests, so PriorityNormal and
+//PriorityLow requests can only use 80% of it. The shares of all priorities must add up to less than 1.
+func (rl *RateLimitConfig) SetPriorityReserve(priority Priority, share float64) {
+	if !priority.isValid() {
+		return
+	}
+
+	rl.priorityReserves[priority] = share
+}
+
+//validatePriorityReserves returns an error if the reserved shares of the request limit are not possible.
+func (rl *RateLimitConfig) validatePriorityReserves() error {
+	var total float64
+	for priority, share := range rl.priorityReserves {
+		if share < 0 || share > 1 {
+			return fmt.Errorf("reserve of priority %v must be between 0 and 1, got %v", priority, share)
+		}
+		total += share
+	}
+
+	//leaves at least one request for the lowest priority, since a limit of 0 is an infinite rate
+	if total >= 1 {
+		return fmt.Errorf("priority reserves must add up to less than 1, got %v", total)
+	}
+
+	return nil
+}
+
+//forPriority returns the config as it applies to a request of the given priority. The request limit
+//excludes the requests reserved for higher priorities.
+func (rl RateLimitConfig) forPriority(priority Priority) RateLimitConfig {
+	if rl.requestLimit == 0 {
+		return rl
+	}
+
+	var reserved int
+	for higher := priority + 1; higher < numOfPriorities; higher++ {
+		if higher.isValid() {
+			reserved += int(float64(rl.requestLimit) * rl.priorityReserves[higher])
+		}
+	}
+
+	rl.requestLimit -= reserved
+	return rl
+}
+
+func (p Priority) isValid() bool {
+	return p >= PriorityLow && p < numOfPriorities
+}
+
+//clamp returns the closest valid priority
+func (p Priority) clamp() Priority {
+	if p < PriorityLow {
+		return PriorityLow
+	}
+	if p > PriorityHigh {
+		return PriorityHigh
+	}
+
+	return p
+}
@@ -0,0 +1,112 @@
+package limiter
+
+import "testing"
+
+func Test_ForPriority(t *testing.T) {
+	config := NewRateLimitConfig("test_host_1", 20, 1, 20, 1, 0)
+	config.SetPriorityReserve(PriorityHigh, 0.2)
+	config.SetPriorityReserve(PriorityNormal, 0.1)
+
+	type TestPriority struct {
+		priority      Priority
+		expectedLimit int
+	}
+
+	testCases := []TestPriority{
+		{PriorityHigh, 20},
+		{PriorityNormal, 16},
+		{PriorityLow, 14},
+	}
+
+	for i := 0; i < len(testCases); i++ {
+		limit := config.forPriority(testCases[i].priority).requestLimit
+		if limit != testCases[i].expectedLimit {
+			t.Errorf("Loop: %v. Expected limit %v, got: %v", i, testCases[i].expectedLimit, limit)
+		}
+	}
+
+	infinite := NewRateLimitConfig("test_host_2", 0, 0, 0, 0, 0)
+	infinite.SetPriorityReserve(PriorityHigh, 0.5)
+	if limit := infinite.forPriority(PriorityLow).requestLimit; limit != 0 {
+		t.Errorf("Expected an infinite rate to stay infinite, got: %v", limit)
+	}
+}
+
+func Test_WithPriorityClamps(t *testing.T) {
+	type TestClamp struct {
+		priority Priority
+		expected Priority
+	}
+
+	testCases := []TestClamp{
+		{PriorityLow, PriorityLow},
+		{PriorityHigh, PriorityHigh},
+		{Priority(7), PriorityHigh},
+		{Priority(-1), PriorityLow},
+	}
+
+	for i := 0; i < len(testCases); i++ {
+		options := newAcquireOptions([]AcquireOption{WithPriority(testCases[i].priority)})
+		if options.priority != testCases[i].expected {
+			t.Errorf("Loop: %v. Expected priority %v, got: %v", i, testCases[i].expected, options.priority)
+		}
+	}
+}
+
+func Test_ValidatePriorityReserves(t *testing.T) {
+	type TestReserves struct {
+		high      float64
+		normal    float64
+		expectErr bool
+	}
+
+	testCases := []TestReserves{
+		{0.2, 0, false},
+		{0.5, 0.4, false},
+		{0.5, 0.5, true},
+		{1.2, 0, true},
+		{-0.1, 0, true},
+	}
+
+	for i := 0; i < len(testCases); i++ {
+		config := NewRateLimitConfig("test_host_1", 20, 1, 20, 1, 0)
+		config.SetPriorityReserve(PriorityHigh, testCases[i].high)
+		config.SetPriorityReserve(PriorityNormal, testCases[i].normal)
+
+		if err := config.Validate(); (err != nil) != testCases[i].expectErr {
+			t.Errorf("Loop: %v. Expected error: %v, got: %v", i, testCases[i].expectErr, err)
+		}
+	}
+}
+
+func Test_CanMakeRequestWithPriority(t *testing.T) {
+	deleteTestHosts(t, "priorityHost")
+
+	config := newTestConfig("priorityHost", 10)
+	config.SetPriorityReserve(PriorityHigh, 0.2)
+
+	limiter, err := NewLimiter(config, pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 8; i++ {
+		if canMake, _, err := limiter.CanMakeRequest(1, WithPriority(PriorityLow)); err != nil || !canMake {
+			t.Fatalf("Expected low priority request %v to be allowed, got: %v, %v", i, canMake, err)
+		}
+	}
+
+	if canMake, _, err := limiter.CanMakeRequest(1); err != nil || canMake {
+		t.Errorf("Expected a normal priority request to be denied by the reserve, got: %v, %v", canMake, err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if canMake, _, err := limiter.CanMakeRequest(1, WithPriority(PriorityHigh)); err != nil || !canMake {
+			t.Errorf("Expected high priority request %v to use the reserve, got: %v, %v", i, canMake, err)
+		}
+	}
+
+	if _, _, err := limiter.CanMakeRequest(9, WithPriority(PriorityLow)); err == nil {
+		t.Errorf("Expected an error for a low priority request that can never fit outside the reserve")
+	}
+}
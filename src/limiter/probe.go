@@ -0,0 +1,137 @@
+package limiter
+
+//minProbeWait is the shortest time a request waits while the probes of a recovering host are in flight.
+const minProbeWait = 50
+
+//SetRecoveryProbes makes a host recover carefully after the cooldown of HitRateLimit or HitBan.
+//Once the cooldown is over, only the given number of probe requests are approved across every
+//process, and normal throughput resumes once that many probes have completed with RequestSuccessful.
+//Only requests a Limiter granted as probes count as probes when they complete, so requests that were
+//approved before the cooldown do not end it.
+//A probe that hits the ratelimit again starts another cooldown. The default of 0 resumes normal
+//throughput as soon as the cooldown is over.
+//
+//	config.SetRecoveryProbes(1)
+func (rl *RateLimitConfig) SetRecoveryProbes(probes int) {
+	if probes < 0 {
+		probes = 0
+	}
+
+	rl.recoveryProbes = probes
+}
+
+//isRecovering returns true while the host still needs successful probes before normal throughput resumes
+func (r *RequestsStatus) isRecovering() bool {
+	return r.probes > 0
+}
+
+//probeAllows checks if a request fits into the probes of a recovering host. It returns false and
+//how long to wait if the probes are already in flight.
+func (r *RequestsStatus) probeAllows(requestWeight int, config RateLimitConfig) (bool, int64) {
+	inFlight := r.probing
+	//a probe that finished after another cooldown started can leave the count below 0
+	if inFlight < 0 {
+		inFlight = 0
+	}
+
+	if !r.isRecovering() || inFlight+requestWeight <= r.probes {
+		return true, 0
+	}
+
+	//the probes in flight usually finish within the time between requests
+	wait := config.timeBetweenRequests
+	if wait < minProbeWait {
+		wait = minProbeWait
+	}
+
+	return false, wait
+}
+
+//Grant remembers which levels of a limiter granted a request as a recovery probe, so completing the
+//request counts it as a probe exactly when it was one.
+//
+//	var grant Grant
+//	canMake, sleepTime, err := limiter.CanMakeRequest(1, WithGrant(&grant))
+//	//make the request
+//	err = limiter.RequestSuccessful(1, WithGrant(&grant))
+//Without a Grant, a completed request counts as a probe while the Limiter has probes in flight, which
+//can count a request that was approved before the cooldown. A Grant describes one request at a time.
+type Grant struct {
+	probes map[*Limiter]int64 //levels that granted the request as a probe, by the lastErrorTime of their cooldown
+}
+
+//WithGrant fills in the Grant with how the request was granted by CanMakeRequest, WaitForRatelimit or
+//Reserve, and passes it on to RequestSuccessful or RequestCancelled once the request has completed.
+func WithGrant(grant *Grant) AcquireOption {
+	return func(o *acquireOptions) {
+		o.grant = grant
+	}
+}
+
+//recordGrant fills in the grant with the levels that granted the request as a probe.
+//The limiters must be locked.
+func recordGrant(grant *Grant, limiters []*Limiter, probed []int) {
+	if grant == nil {
+		return
+	}
+
+	grant.probes = nil
+	for i, l := range limiters {
+		if probed[i] <= 0 {
+			continue
+		}
+
+		if grant.probes == nil {
+			grant.probes = make(map[*Limiter]int64)
+		}
+		grant.probes[l] = l.status.lastErrorTime
+	}
+}
+
+//copyTo sets the other grant to the same probes, if there is one
+func (g Grant) copyTo(other *Grant) {
+	if other == nil {
+		return
+	}
+
+	other.probes = make(map[*Limiter]int64, len(g.probes))
+	for l, epoch := range g.probes {
+		other.probes[l] = epoch
+	}
+}
+
+//recordProbes remembers the weight this limiter granted as probes after the cooldown of its cached status.
+//The limiter must be locked.
+func (l *Limiter) recordProbes(weight int) {
+	if weight <= 0 {
+		return
+	}
+
+	//probes granted after an earlier cooldown no longer count
+	if l.probeEpoch != l.status.lastErrorTime {
+		l.probeEpoch = l.status.lastErrorTime
+		l.probeGrants = 0
+	}
+
+	l.probeGrants += weight
+}
+
+//takeProbe returns true if a request of the given weight that has completed was granted as a probe by
+//this limiter after the current cooldown, and forgets the grant. A request with a Grant is only a probe
+//if the Grant says so. The limiter must be locked.
+func (l *Limiter) takeProbe(weight int, grant *Grant) bool {
+	if l.probeEpoch != l.status.lastErrorTime || l.probeGrants < weight {
+		return false
+	}
+
+	if grant != nil {
+		epoch, ok := grant.probes[l]
+		if !ok || epoch != l.probeEpoch {
+			return false
+		}
+		delete(grant.probes, l)
+	}
+
+	l.probeGrants -= weight
+	return true
+}
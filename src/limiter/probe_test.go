@@ -0,0 +1,142 @@
+package limiter
+
+import (
+	"testing"
+
+	"github.com/mediocregopher/radix/v3"
+)
+
+func Test_ProbeAllows(t *testing.T) {
+	config := NewRateLimitConfig("test_host_1", 20, 1, 20, 1, 0)
+
+	type TestProbe struct {
+		status   RequestsStatus
+		weight   int
+		expected bool
+	}
+
+	recovering := func(inFlight int, remaining int) RequestsStatus {
+		status := newRequestsStatus(0, 0, 0, 0)
+		status.probing = inFlight
+		status.probes = remaining
+		return status
+	}
+
+	//requests approved before the cooldown are still pending, but are not probes
+	pendingBeforeCooldown := recovering(0, 1)
+	pendingBeforeCooldown.pendingRequests = 3
+
+	testCases := []TestProbe{
+		{recovering(0, 0), 5, true},  //not recovering
+		{recovering(3, -2), 1, true}, //more probes succeeded than needed
+		{recovering(0, 1), 1, true},  //first probe
+		{recovering(1, 1), 1, false}, //probe already in flight
+		{recovering(1, 2), 1, true},  //second of two probes
+		{recovering(0, 1), 2, false}, //request heavier than the probes
+		{recovering(-1, 1), 1, true}, //a probe finished after another cooldown started
+		{pendingBeforeCooldown, 1, true},
+	}
+
+	for i := 0; i < len(testCases); i++ {
+		canMake, wait := testCases[i].status.probeAllows(testCases[i].weight, config)
+		if canMake != testCases[i].expected {
+			t.Errorf("Loop: %v. Expected %v, got: %v", i, testCases[i].expected, canMake)
+		}
+
+		if !canMake && wait < minProbeWait {
+			t.Errorf("Loop: %v. Expected to wait at least %v, got: %v", i, minProbeWait, wait)
+		}
+	}
+}
+
+func Test_RecoveryProbes(t *testing.T) {
+	deleteTestHosts(t, "probeHost")
+
+	config := newTestConfig("probeHost", 10)
+	config.SetRecoveryProbes(1)
+
+	limiter, err := NewLimiter(config, pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if canMake, _, err := limiter.CanMakeRequest(1); err != nil || !canMake {
+		t.Fatalf("Expected the request to be allowed, got: %v, %v", canMake, err)
+	}
+
+	if err := limiter.HitRateLimit(1); err != nil {
+		t.Fatal(err)
+	}
+
+	if canMake, _, err := limiter.CanMakeRequest(1); err != nil || !canMake {
+		t.Fatalf("Expected the probe to be allowed, got: %v, %v", canMake, err)
+	}
+
+	if canMake, wait, err := limiter.CanMakeRequest(1); err != nil || canMake || wait <= 0 {
+		t.Errorf("Expected requests to wait for the probe, got: %v, %v, %v", canMake, wait, err)
+	}
+
+	if err := limiter.RequestSuccessful(1); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if canMake, _, err := limiter.CanMakeRequest(1); err != nil || !canMake {
+			t.Errorf("Loop: %v. Expected normal throughput after the probe, got: %v, %v", i, canMake, err)
+		}
+	}
+}
+
+func Test_RequestsBeforeCooldownAreNotProbes(t *testing.T) {
+	deleteTestHosts(t, "probeHost2")
+
+	config := newTestConfig("probeHost2", 10)
+	config.SetRecoveryProbes(1)
+
+	limiter, err := NewLimiter(config, pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//two requests are approved, and one of them hits the ratelimit
+	grants := make([]Grant, 3)
+	for i := 0; i < 2; i++ {
+		if canMake, _, err := limiter.CanMakeRequest(1, WithGrant(&grants[i])); err != nil || !canMake {
+			t.Fatalf("Loop: %v. Expected the request to be allowed, got: %v, %v", i, canMake, err)
+		}
+	}
+
+	if err := limiter.HitRateLimit(1); err != nil {
+		t.Fatal(err)
+	}
+
+	//the other request is still pending, but does not keep the probe from being approved
+	if canMake, _, err := limiter.CanMakeRequest(1, WithGrant(&grants[2])); err != nil || !canMake {
+		t.Fatalf("Expected the probe to be allowed, got: %v, %v", canMake, err)
+	}
+
+	type TestProbeCompletion struct {
+		grant  *Grant
+		probes int
+	}
+
+	testCases := []TestProbeCompletion{
+		{&grants[1], 1}, //approved before the 429, so the recovery goes on
+		{&grants[2], 0}, //the probe
+	}
+
+	for i := 0; i < len(testCases); i++ {
+		if err := limiter.RequestSuccessful(1, WithGrant(testCases[i].grant)); err != nil {
+			t.Fatal(err)
+		}
+
+		var remaining int
+		if err := pool.Do(radix.Cmd(&remaining, "HGET", "status:probeHost2", probes)); err != nil {
+			t.Fatal(err)
+		}
+
+		if remaining != testCases[i].probes {
+			t.Errorf("Loop: %v. Expected %v probes to still be needed, got: %v", i, testCases[i].probes, remaining)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package limiter
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/mediocregopher/radix/v3"
+)
+
+const (
+	//ticketTimeout is the number of milliseconds after its last heartbeat that a ticket
+	//is considered abandoned and is removed from the front of the queue.
+	ticketTimeout = 5000
+	//maxQueueSleep is the longest a waiter sleeps before it refreshes its heartbeat.
+	maxQueueSleep = time.Second
+	//minQueueSleep keeps waiters from polling redis in a tight loop when requests are not spaced out.
+	minQueueSleep = 10 * time.Millisecond
+)
+
+//WithQueue makes WaitForRatelimit admit waiters in the order they arrived, across every
+//process that uses the host. Each waiter takes a numbered ticket and only the waiter at the
+//front of the queue may try to make its request. Waiters further back sleep for a time based
+//on their position in the queue.
+//
+//Every Limiter sharing a host name should be created with WithQueue. CanMakeRequest does not
+//take a ticket, so calling it directly skips the queue.
+func WithQueue() Option {
+	return func(l *Limiter) {
+		l.queue = true
+	}
+}
+
+//waitInQueue takes a ticket and waits until it is at the front of the queue and the request can be made.
+func (l *Limiter) waitInQueue(ctx context.Context, requestWeight int, opts []AcquireOption) (err error) {
+	ticket, err := l.takeTicket()
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if leaveErr := l.leaveQueue(ticket); err == nil {
+			err = leaveErr
+		}
+	}()
+
+	attempt := 0
+	for {
+		position, err := l.queuePosition(ticket)
+		if err != nil {
+			return err
+		}
+
+		if position > 0 {
+			if err := l.sleep(ctx, l.waitPolicy.addJitter(l.queueWait(position))); err != nil {
+				return err
+			}
+			continue
+		}
+
+		canMake, sleepTime, err := l.CanMakeRequest(requestWeight, opts...)
+		if err != nil {
+			return err
+		}
+		if canMake {
+			return nil
+		}
+
+		attempt++
+		sleepTime, err = l.waitPolicy.delay(attempt, sleepTime)
+		if err != nil {
+			return err
+		}
+
+		if sleepTime > maxQueueSleep {
+			sleepTime = maxQueueSleep
+		}
+
+		if err := l.sleep(ctx, sleepTime); err != nil {
+			return err
+		}
+	}
+}
+
+//takeTicket adds a new ticket to the back of the queue
+func (l *Limiter) takeTicket() (string, error) {
+	var number int64
+	if err := l.pool.Do(radix.Cmd(&number, "INCR", l.getTicketsKey())); err != nil {
+		return "", err
+	}
+
+	ticket := strconv.FormatInt(number, 10)
+
+	err := l.pool.Do(radix.Pipeline(
+		radix.FlatCmd(nil, "ZADD", l.getQueueKey(), number, ticket),
+		radix.FlatCmd(nil, "HSET", l.getHeartbeatsKey(), ticket, getUnixTimeMilliseconds()),
+	))
+	if err != nil {
+		return "", err
+	}
+
+	return ticket, nil
+}
+
+//queuePosition refreshes the heartbeat of the ticket and returns how many tickets are in front of it.
+//An abandoned ticket at the front of the queue is removed so it cannot block the queue.
+func (l *Limiter) queuePosition(ticket string) (int, error) {
+	var now int64
+	var position int
+
+	for {
+		now = getUnixTimeMilliseconds()
+
+		var rank radix.MaybeNil
+		rank.Rcv = &position
+
+		err := l.pool.Do(radix.Pipeline(
+			radix.FlatCmd(nil, "HSET", l.getHeartbeatsKey(), ticket, now),
+			radix.Cmd(&rank, "ZRANK", l.getQueueKey(), ticket),
+		))
+		if err != nil {
+			return 0, err
+		}
+
+		if !rank.Nil {
+			break
+		}
+
+		//another waiter thought the ticket was abandoned, so it is put back in the same place.
+		//The heartbeat was just refreshed, so it is not removed again unless it stays without one.
+		number, _ := strconv.ParseInt(ticket, 10, 64)
+		if err := l.pool.Do(radix.FlatCmd(nil, "ZADD", l.getQueueKey(), number, ticket)); err != nil {
+			return 0, err
+		}
+	}
+
+	if position == 0 {
+		return 0, nil
+	}
+
+	var front []string
+	if err := l.pool.Do(radix.Cmd(&front, "ZRANGE", l.getQueueKey(), "0", "0")); err != nil {
+		return 0, err
+	}
+
+	if len(front) == 0 {
+		return position, nil
+	}
+
+	var value string
+	if err := l.pool.Do(radix.Cmd(&value, "HGET", l.getHeartbeatsKey(), front[0])); err != nil {
+		return 0, err
+	}
+
+	//a ticket without a heartbeat is abandoned as well
+	heartbeat, _ := strconv.ParseInt(value, 10, 64)
+	if now-heartbeat > ticketTimeout {
+		if err := l.leaveQueue(front[0]); err != nil {
+			return 0, err
+		}
+		position--
+	}
+
+	return position, nil
+}
+
+//leaveQueue removes the ticket from the queue
+func (l *Limiter) leaveQueue(ticket string) error {
+	return l.pool.Do(radix.Pipeline(
+		radix.Cmd(nil, "ZREM", l.getQueueKey(), ticket),
+		radix.Cmd(nil, "HDEL", l.getHeartbeatsKey(), ticket),
+	))
+}
+
+//queueWait returns how long a waiter at the given position should sleep before checking the queue again.
+//On average one waiter leaves the front of the queue every timeBetweenRequests.
+func (l *Limiter) queueWait(position int) time.Duration {
+	l.mu.Lock()
+	timeBetween := l.config.timeBetweenRequests
+	l.mu.Unlock()
+
+	wait := millisecondsToDuration(int64(position) * timeBetween)
+
+	if wait < minQueueSleep {
+		return minQueueSleep
+	}
+
+	if wait > maxQueueSleep {
+		return maxQueueSleep
+	}
+
+	return wait
+}
@@ -0,0 +1,157 @@
+package limiter
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mediocregopher/radix/v3"
+)
+
+func Test_WaitInQueueOrder(t *testing.T) {
+	deleteTestHosts(t, "queueHost")
+
+	config := NewRateLimitConfigFromWindows("queueHost", Window{10, time.Second}, Window{10, time.Second}, 0)
+
+	var mu sync.Mutex
+	var order []int
+	var wg sync.WaitGroup
+
+	for i := 0; i < 5; i++ {
+		limiter, err := NewLimiter(config, pool, WithQueue())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		wg.Add(1)
+		go func(id int, limiter *Limiter) {
+			defer wg.Done()
+
+			if err := limiter.WaitForRatelimit(1); err != nil {
+				t.Error(err)
+			}
+
+			mu.Lock()
+			order = append(order, id)
+			mu.Unlock()
+		}(i, limiter)
+
+		//gives each waiter time to take its ticket
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	wg.Wait()
+
+	for i := range order {
+		if order[i] != i {
+			t.Errorf("Expected waiters to be admitted in arrival order, got: %v", order)
+			break
+		}
+	}
+
+	var length int
+	if err := pool.Do(radix.Cmd(&length, "ZCARD", "queue:queueHost")); err != nil {
+		t.Fatal(err)
+	}
+	if length != 0 {
+		t.Errorf("Expected every ticket to leave the queue, %v are left", length)
+	}
+}
+
+func Test_WaitInQueueAbandonedTicket(t *testing.T) {
+	deleteTestHosts(t, "queueHost2")
+
+	limiter, err := NewLimiter(newTestConfig("queueHost2", 10), pool, WithQueue())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//a waiter that went away without leaving the queue
+	err = pool.Do(radix.Pipeline(
+		radix.Cmd(nil, "ZADD", "queue:queueHost2", "0", "abandoned"),
+		radix.FlatCmd(nil, "HSET", "heartbeats:queueHost2", "abandoned", getUnixTimeMilliseconds()-2*ticketTimeout),
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- limiter.WaitForRatelimit(1)
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Errorf("Expected the abandoned ticket to be removed from the front of the queue")
+	}
+}
+
+func Test_QueuePositionOfRemovedTicket(t *testing.T) {
+	deleteTestHosts(t, "queueHost3")
+
+	limiter, err := NewLimiter(newTestConfig("queueHost3", 10), pool, WithQueue())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := limiter.takeTicket()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := limiter.takeTicket()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//another waiter removed the first ticket, thinking it was abandoned
+	if err := limiter.leaveQueue(first); err != nil {
+		t.Fatal(err)
+	}
+
+	type TestPosition struct {
+		ticket   string
+		position int
+	}
+
+	testCases := []TestPosition{
+		{first, 0}, //put back in front of the second ticket
+		{second, 1},
+	}
+
+	for i := 0; i < len(testCases); i++ {
+		position, err := limiter.queuePosition(testCases[i].ticket)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if position != testCases[i].position {
+			t.Errorf("Loop: %v. Expected position %v, got: %v", i, testCases[i].position, position)
+		}
+	}
+}
+
+func Test_QueueWait(t *testing.T) {
+	limiter := Limiter{host: "test_host_1", config: NewRateLimitConfig("test_host_1", 20, 1, 20, 1, 0)}
+
+	type TestQueueWait struct {
+		position int
+		expected time.Duration
+	}
+
+	testCases := []TestQueueWait{
+		{0, minQueueSleep},
+		{4, 200 * time.Millisecond},
+		{100, maxQueueSleep},
+	}
+
+	for i := 0; i < len(testCases); i++ {
+		if wait := limiter.queueWait(testCases[i].position); wait != testCases[i].expected {
+			t.Errorf("Loop: %v. Expected %v, got: %v", i, testCases[i].expected, wait)
+		}
+	}
+}
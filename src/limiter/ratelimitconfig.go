@@ -1,7 +1,11 @@
 package limiter
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"strconv"
+	"time"
 
 	"github.com/mediocregopher/radix/v3"
 )
@@ -12,20 +16,103 @@ import (
 //it is imperative that each Limiter you create is initialized with a RateLimitConfig that has the same
 //host name otherwise the Limiter structs will not be able to communicate and you will definitely hit
 //the ratelimit.
+//
+//Every setting of a config, such as its burst mode or ban policy, is shared through redis. The config a host
+//was first saved with, or was last given to UpdateConfig, is the one every Limiter of the host uses.
 type RateLimitConfig struct {
-	host                string //may change to different data type later
-	requestLimit        int    //how many requests can be made in the given timePeriod
-	timePeriod          int64  //how long the period lasts in seconds
-	timeBetweenRequests int64  //is the minimum number of milliseconds between requests
-	waitAfterHitLimit   int64  //is the number of seconds after hitting a rate limit, where no requests will be approved
+	host                string                   //may change to different data type later
+	requestLimit        int                      //how many requests can be made in the given timePeriod
+	timePeriod          int64                    //how long the period lasts in milliseconds
+	timeBetweenRequests int64                    //is the minimum number of milliseconds between requests
+	waitAfterHitLimit   int64                    //is the number of milliseconds after hitting a rate limit, where no requests will be approved
+	burstMode           bool                     //allows requests to be made back to back as long as the period's limit holds
+	priorityReserves    [numOfPriorities]float64 //share of the requestLimit set aside for each priority
+	version             int64                    //version of the config in the database the shared fields were loaded from
+	banPolicy           BanPolicy                //how HitBan reacts to a ban, the default policy if it is the zero value
+	recoveryProbes      int                      //number of successful probes needed after a cooldown
+	warmUp              WarmUp                   //ramps up the request limit, no warm-up if its duration is 0
+	minLimit            int                      //lowest requestLimit adjustments can lower it to
+	maxLimit            int                      //highest requestLimit adjustments can raise it to, 0 if unbounded
+	reductionDecay      int64                    //milliseconds it takes for a reduction to wear off, 0 if they do not
+	baseLimit           int                      //requestLimit the host was configured with, 0 if it is not known yet
+	reducedAt           int64                    //time in milliseconds of the last reduction, 0 if there was none
+	discovery           Discovery                //raises the limit to find the real rate limit, off if its step is 0
+	stableLimit         int                      //highest limit discovery has found free of errors
+	raisedAt            int64                    //time in milliseconds discovery last raised the limit
+	settled             bool                     //discovery has found the real rate limit
+}
+
+//Window is a ratelimit of Limit requests per Period. Periods have millisecond precision.
+//
+//A Window with a Limit or Period of 0 is an infinite rate.
+type Window struct {
+	Limit  int
+	Period time.Duration
 }
 
 const (
 	limit               = "limit"
-	timePeriod          = "timePeriod"
+	timePeriodMs        = "timePeriodMs" //stored in milliseconds
 	timeBetweenRequests = "timeBetween"
+	configVersion       = "version" //incremented every time the config in the database changes
+	baseLimit           = "baseLimit"
+	reducedAt           = "reducedAt"
+	stableLimit         = "stableLimit"
+	raisedAt            = "raisedAt"
+	settled             = "settled"
+	settings            = "settings" //every other setting of the config, as JSON
 )
 
+//configSettings is the JSON form of the settings of a config that are not saved in a field of their own
+type configSettings struct {
+	WaitAfterHitLimit int64                    `json:"waitAfterHitLimit"`
+	BurstMode         bool                     `json:"burstMode"`
+	PriorityReserves  [numOfPriorities]float64 `json:"priorityReserves"`
+	BanPolicy         BanPolicy                `json:"banPolicy"`
+	RecoveryProbes    int                      `json:"recoveryProbes"`
+	WarmUp            WarmUp                   `json:"warmUp"`
+	MinLimit          int                      `json:"minLimit"`
+	MaxLimit          int                      `json:"maxLimit"`
+	ReductionDecay    int64                    `json:"reductionDecay"`
+	Discovery         Discovery                `json:"discovery"`
+}
+
+//getSettings returns the settings of the config that are not saved in a field of their own
+func (rl RateLimitConfig) getSettings() configSettings {
+	return configSettings{
+		WaitAfterHitLimit: rl.waitAfterHitLimit,
+		BurstMode:         rl.burstMode,
+		PriorityReserves:  rl.priorityReserves,
+		BanPolicy:         rl.banPolicy,
+		RecoveryProbes:    rl.recoveryProbes,
+		WarmUp:            rl.warmUp,
+		MinLimit:          rl.minLimit,
+		MaxLimit:          rl.maxLimit,
+		ReductionDecay:    rl.reductionDecay,
+		Discovery:         rl.discovery,
+	}
+}
+
+//setSettings replaces the settings of the config that are not saved in a field of their own
+func (rl *RateLimitConfig) setSettings(s configSettings) {
+	rl.waitAfterHitLimit = s.WaitAfterHitLimit
+	rl.burstMode = s.BurstMode
+	rl.priorityReserves = s.PriorityReserves
+	rl.banPolicy = s.BanPolicy
+	rl.recoveryProbes = s.RecoveryProbes
+	rl.warmUp = s.WarmUp
+	rl.minLimit = s.MinLimit
+	rl.maxLimit = s.MaxLimit
+	rl.reductionDecay = s.ReductionDecay
+	rl.discovery = s.Discovery
+}
+
+//settingsToDatabase returns the settings of the config as they are saved in the settings field
+func (rl RateLimitConfig) settingsToDatabase() (string, error) {
+	data, err := json.Marshal(rl.getSettings())
+	return string(data), err
+}
+
 //NewRateLimitConfig creates a rate limit config for a Limiter struct.
 //
 //If you want to coordinate requests to one api across multiple threads, routines, containers, etc,
@@ -36,21 +123,136 @@ const (
 //NewRateLimitConfig takes in two rates: a sustained ratelimit and a burst ratelimit. If the api you are
 //making requests to only uses one ratelimit, enter in that rate for both the sustained and burst ratelimit.
 //
-//	config := NewRateLimitConfig("exampleHostName", 1200, 60, 20, 1)
+//	config := NewRateLimitConfig("exampleHostName", 1200, 60, 20, 1, 3)
 //The time periods of both rates are in terms of seconds so the config above has a sustained ratelimit of
 //1200 requests per 60 seconds and a burst ratelimit of 20 requests per second.
 //
-//waitAfterHitLimit is the amount of time in milliseconds the limiter will wait before allowing more requests after
-//hitting the ratelimit.
+//waitAfterHitLimit is the amount of time in seconds the limiter will wait before allowing more requests after
+//hitting the ratelimit. Use NewRateLimitConfigFromWindows for periods shorter than a second.
 func NewRateLimitConfig(host string, sustainedRequestLimit int, sustainedTimePeriod int64, burstRequestLimit int, burstTimePeriod int64, waitAfterHitLimit int64) RateLimitConfig {
-	rl := RateLimitConfig{host, 0, 0, 0, waitAfterHitLimit}
+	return NewRateLimitConfigFromWindows(
+		host,
+		Window{sustainedRequestLimit, time.Duration(sustainedTimePeriod) * time.Second},
+		Window{burstRequestLimit, time.Duration(burstTimePeriod) * time.Second},
+		time.Duration(waitAfterHitLimit)*time.Second,
+	)
+}
+
+//NewRateLimitConfigFromWindows creates a rate limit config for a Limiter struct from a sustained and a burst Window.
+//It allows ratelimits such as 5 requests per 200 milliseconds that cannot be expressed in whole seconds.
+//
+//	config := NewRateLimitConfigFromWindows(
+//		"exampleHostName",
+//		Window{Limit: 1200, Period: time.Minute},
+//		Window{Limit: 5, Period: 200 * time.Millisecond},
+//		3*time.Second,
+//	)
+//waitAfterHitLimit is the amount of time the limiter will wait before allowing more requests after
+//hitting the ratelimit.
+func NewRateLimitConfigFromWindows(host string, sustained Window, burst Window, waitAfterHitLimit time.Duration) RateLimitConfig {
+	rl := RateLimitConfig{host: host, waitAfterHitLimit: durationToMilliseconds(waitAfterHitLimit)}
 
-	rl.requestLimit, rl.timePeriod = determineLowerRate(sustainedRequestLimit, sustainedTimePeriod, burstRequestLimit, burstTimePeriod)
+	rl.requestLimit, rl.timePeriod = determineLowerRate(
+		sustained.Limit,
+		durationToMilliseconds(sustained.Period),
+		burst.Limit,
+		durationToMilliseconds(burst.Period),
+	)
 	rl.setTimeBetweenRequests()
 
 	return rl
 }
 
+//NewCheckedRateLimitConfig creates a rate limit config the same way NewRateLimitConfigFromWindows does,
+//but returns an error describing the problem if any of the values it is given cannot make a working Limiter.
+//
+//	config, err := NewCheckedRateLimitConfig(
+//		"exampleHostName",
+//		Window{Limit: 1200, Period: time.Minute},
+//		Window{Limit: 20, Period: time.Second},
+//		3*time.Second,
+//	)
+func NewCheckedRateLimitConfig(host string, sustained Window, burst Window, waitAfterHitLimit time.Duration) (RateLimitConfig, error) {
+	if err := sustained.validate(); err != nil {
+		return RateLimitConfig{}, fmt.Errorf("sustained ratelimit: %v", err)
+	}
+
+	if err := burst.validate(); err != nil {
+		return RateLimitConfig{}, fmt.Errorf("burst ratelimit: %v", err)
+	}
+
+	rl := NewRateLimitConfigFromWindows(host, sustained, burst, waitAfterHitLimit)
+	if err := rl.Validate(); err != nil {
+		return RateLimitConfig{}, err
+	}
+
+	return rl, nil
+}
+
+//Validate returns an error describing the first field of the config that cannot make a working Limiter.
+//NewLimiter validates the config it is given, so configs made with NewRateLimitConfig are checked before use.
+func (rl RateLimitConfig) Validate() error {
+	if rl.host == "" {
+		return errors.New("host name must not be empty")
+	}
+
+	if rl.requestLimit < 0 {
+		return fmt.Errorf("request limit must not be negative, got %v", rl.requestLimit)
+	}
+
+	if rl.timePeriod < 0 {
+		return fmt.Errorf("time period must not be negative, got %vms", rl.timePeriod)
+	}
+
+	if rl.requestLimit > 0 && rl.timePeriod == 0 {
+		return fmt.Errorf("time period must be at least one millisecond for a limit of %v requests", rl.requestLimit)
+	}
+
+	if rl.waitAfterHitLimit < 0 {
+		return fmt.Errorf("wait after hitting the limit must not be negative, got %vms", rl.waitAfterHitLimit)
+	}
+
+	if err := rl.banPolicy.validate(); err != nil {
+		return err
+	}
+
+	if err := rl.warmUp.validate(); err != nil {
+		return err
+	}
+
+	if err := rl.validateLimitBounds(); err != nil {
+		return err
+	}
+
+	if err := rl.discovery.validate(); err != nil {
+		return err
+	}
+
+	return rl.validatePriorityReserves()
+}
+
+//checkRequestWeight returns an error if a request with the given weight could never be made
+//because it would not fit into the request limit, no matter how long the caller waited.
+func (rl *RateLimitConfig) checkRequestWeight(requestWeight int) error {
+	if requestWeight <= 0 {
+		return fmt.Errorf("request weight must be positive, got %v", requestWeight)
+	}
+
+	if rl.requestLimit > 0 && requestWeight > rl.requestLimit {
+		return fmt.Errorf("request weight %v can never fit in the request limit of %v for host %v", requestWeight, rl.requestLimit, rl.host)
+	}
+
+	return nil
+}
+
+//SetBurstMode controls how requests are spread out over a period. By default requests are evenly
+//spaced, so a limit of 20 requests per second allows one request every 50 milliseconds.
+//With burst mode enabled, requests may be made back to back until the limit of the period is reached.
+func (rl *RateLimitConfig) SetBurstMode(enabled bool) {
+	rl.burstMode = enabled
+}
+
+//determineLowerRate returns the lower of the two rates. The time periods are in milliseconds.
 func determineLowerRate(sustainedRequestLimit int, sustainedTimePeriod int64, burstRequestLimit int, burstTimePeriod int64) (int, int64) {
 	if (sustainedRequestLimit == 0 || sustainedTimePeriod == 0) && (burstRequestLimit == 0 || burstTimePeriod == 0) {
 		//both infinite rates
@@ -58,23 +260,23 @@ func determineLowerRate(sustainedRequestLimit int, sustainedTimePeriod int64, bu
 	}
 	if sustainedRequestLimit == 0 || sustainedTimePeriod == 0 {
 		//sustained is an infinite rate
-		limit, time := reduceFraction(int64(burstRequestLimit), burstTimePeriod)
-		return int(limit), time
+		limit, period := reduceRate(int64(burstRequestLimit), burstTimePeriod)
+		return int(limit), period
 	}
 	if burstRequestLimit == 0 || burstTimePeriod == 0 {
 		//burst is an infinite rate
-		limit, time := reduceFraction(int64(sustainedRequestLimit), sustainedTimePeriod)
-		return int(limit), time
+		limit, period := reduceRate(int64(sustainedRequestLimit), sustainedTimePeriod)
+		return int(limit), period
 	}
 	//determines which is the lower effective rate
 	if burstRequestLimit*int(sustainedTimePeriod) > sustainedRequestLimit*int(burstTimePeriod) {
 		//sustained is the lower rate
-		lim, period := reduceFraction(int64(sustainedRequestLimit), sustainedTimePeriod)
+		lim, period := reduceRate(int64(sustainedRequestLimit), sustainedTimePeriod)
 		return int(lim), period
 	}
 
 	//burst is the lower rate or they are equal
-	lim, period := reduceFraction(int64(burstRequestLimit), burstTimePeriod)
+	lim, period := reduceRate(int64(burstRequestLimit), burstTimePeriod)
 
 	return int(lim), period
 }
@@ -85,30 +287,89 @@ func (rl *RateLimitConfig) setTimeBetweenRequests() {
 		return
 	}
 
-	time := rl.timePeriod * 1000
-	rl.timeBetweenRequests = time / int64(rl.requestLimit)
+	rl.timeBetweenRequests = rl.timePeriod / int64(rl.requestLimit)
 }
 
 func (rl *RateLimitConfig) updateConfigFromDatabase(c radix.Conn, key string) error {
 	var values []string
 
-	err := c.Do(radix.Cmd(&values, "HVALS", key))
+	//HMGET returns the fields in the order they are asked for, unlike HVALS
+	err := c.Do(radix.Cmd(&values, "HMGET", key, limit, timePeriodMs, timeBetweenRequests, configVersion, baseLimit, reducedAt, stableLimit, raisedAt, settled, settings))
 	if err != nil {
 		return err
 	}
 
-	if len(values) != 3 {
+	//the config does not exist in the database yet
+	if len(values) != 10 || values[0] == "" {
 		return nil
 	}
 
 	limit, _ := strconv.Atoi(values[0])
 	timePeriod, _ := strconv.ParseInt(values[1], 10, 64)
 	timeBetween, _ := strconv.ParseInt(values[2], 10, 64)
+	version, _ := strconv.ParseInt(values[3], 10, 64)
+	//configs saved before reductions could decay have neither field
+	base, _ := strconv.Atoi(values[4])
+	reduced, _ := strconv.ParseInt(values[5], 10, 64)
+	stable, _ := strconv.Atoi(values[6])
+	raised, _ := strconv.ParseInt(values[7], 10, 64)
 
-	*rl = RateLimitConfig{rl.host, limit, timePeriod, timeBetween, rl.waitAfterHitLimit}
+	//configs saved before the settings were shared keep the settings the limiter was given
+	if values[9] != "" {
+		var s configSettings
+		if err := json.Unmarshal([]byte(values[9]), &s); err != nil {
+			return err
+		}
+		rl.setSettings(s)
+	}
+
+	rl.requestLimit = limit
+	rl.timePeriod = timePeriod
+	rl.timeBetweenRequests = timeBetween
+	rl.version = version
+	rl.baseLimit = base
+	rl.reducedAt = reduced
+	rl.stableLimit = stable
+	rl.raisedAt = raised
+	rl.settled = values[8] == "1"
 	return nil
 }
 
+//getVersionFromDatabase returns the version of the config in the database, which is 0 if the config has no version
+func getVersionFromDatabase(c radix.Conn, key string) (int64, error) {
+	var value string
+	if err := c.Do(radix.Cmd(&value, "HGET", key, configVersion)); err != nil {
+		return 0, err
+	}
+
+	version, _ := strconv.ParseInt(value, 10, 64)
+	return version, nil
+}
+
+func (w Window) validate() error {
+	if w.Limit < 0 {
+		return fmt.Errorf("limit must not be negative, got %v", w.Limit)
+	}
+
+	if w.Period < 0 {
+		return fmt.Errorf("period must not be negative, got %v", w.Period)
+	}
+
+	if w.Period > 0 && w.Period < time.Millisecond {
+		return fmt.Errorf("period must be at least one millisecond, got %v", w.Period)
+	}
+
+	return nil
+}
+
+func durationToMilliseconds(d time.Duration) int64 {
+	return int64(d / time.Millisecond)
+}
+
+func millisecondsToDuration(milliseconds int64) time.Duration {
+	return time.Duration(milliseconds) * time.Millisecond
+}
+
 func gcd(a int64, b int64) int64 {
 	//Calculate GCD
 	c := a % b
@@ -121,6 +382,17 @@ func gcd(a int64, b int64) int64 {
 	return b
 }
 
+//reduceRate reduces a rate with a period in milliseconds to its shortest whole second period.
+//Periods that are not a whole number of seconds are kept as they are.
+func reduceRate(limit int64, period int64) (int64, int64) {
+	if period%1000 != 0 {
+		return limit, period
+	}
+
+	lim, seconds := reduceFraction(limit, period/1000)
+	return lim, seconds * 1000
+}
+
 func reduceFraction(numerator int64, denominator int64) (int64, int64) {
 	gcd := gcd(numerator, denominator)
 	return numerator / gcd, denominator / gcd
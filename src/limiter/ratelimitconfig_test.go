@@ -1,6 +1,9 @@
 package limiter
 
-import "testing"
+import (
+	"testing"
+	"time"
+)
 
 func Test_DetermineLowerRate(t *testing.T) {
 	type testConfig struct {
@@ -20,24 +23,34 @@ func Test_DetermineLowerRate(t *testing.T) {
 			0,
 		},
 		{
-			10, 0, 35, 1,
+			10, 0, 35, 1000,
 			35,
-			1,
+			1000,
 		},
 		{
-			1000, 1, 20, 0,
+			1000, 1000, 20, 0,
+			1000,
 			1000,
-			1,
 		},
 		{
-			1300, 60, 20, 1,
+			1300, 60000, 20, 1000,
 			20,
-			1,
+			1000,
 		},
 		{
-			1100, 60, 20, 1,
+			1100, 60000, 20, 1000,
 			55,
-			3,
+			3000,
+		},
+		{
+			6000, 60000, 5, 200,
+			5,
+			200,
+		},
+		{
+			1200, 60000, 50, 500,
+			20,
+			1000,
 		},
 	}
 
@@ -54,6 +67,108 @@ func Test_DetermineLowerRate(t *testing.T) {
 	}
 }
 
+func Test_NewRateLimitConfigFromWindows(t *testing.T) {
+	type testConfig struct {
+		sustained         Window
+		burst             Window
+		waitAfterHitLimit time.Duration
+
+		expected RateLimitConfig
+	}
+
+	testCases := []testConfig{
+		{
+			Window{1200, time.Minute},
+			Window{20, time.Second},
+			3 * time.Second,
+			RateLimitConfig{host: "host", requestLimit: 20, timePeriod: 1000, timeBetweenRequests: 50, waitAfterHitLimit: 3000},
+		},
+		{
+			Window{6000, time.Minute},
+			Window{5, 200 * time.Millisecond},
+			500 * time.Millisecond,
+			RateLimitConfig{host: "host", requestLimit: 5, timePeriod: 200, timeBetweenRequests: 40, waitAfterHitLimit: 500},
+		},
+		{
+			Window{0, 0},
+			Window{3, 10 * time.Millisecond},
+			0,
+			RateLimitConfig{host: "host", requestLimit: 3, timePeriod: 10, timeBetweenRequests: 3, waitAfterHitLimit: 0},
+		},
+	}
+
+	for i := 0; i < len(testCases); i++ {
+		config := NewRateLimitConfigFromWindows("host", testCases[i].sustained, testCases[i].burst, testCases[i].waitAfterHitLimit)
+
+		if config != testCases[i].expected {
+			t.Errorf("Loop: %v. Expected: %v, got %v", i, testCases[i].expected, config)
+		}
+	}
+
+	legacy := NewRateLimitConfig("host", 1200, 60, 20, 1, 3)
+	windows := NewRateLimitConfigFromWindows("host", Window{1200, time.Minute}, Window{20, time.Second}, 3*time.Second)
+	if legacy != windows {
+		t.Errorf("Expected: %v, got %v", windows, legacy)
+	}
+}
+
+func Test_NewCheckedRateLimitConfig(t *testing.T) {
+	type testConfig struct {
+		name              string
+		host              string
+		sustained         Window
+		burst             Window
+		waitAfterHitLimit time.Duration
+
+		expectErr bool
+	}
+
+	testCases := []testConfig{
+		{"valid", "host", Window{1200, time.Minute}, Window{20, time.Second}, time.Second, false},
+		{"both infinite", "host", Window{0, 0}, Window{0, 0}, 0, false},
+		{"empty host", "", Window{1200, time.Minute}, Window{20, time.Second}, time.Second, true},
+		{"negative sustained limit", "host", Window{-5, time.Minute}, Window{20, time.Second}, time.Second, true},
+		{"negative burst limit", "host", Window{1200, time.Minute}, Window{-20, 0}, time.Second, true},
+		{"negative period", "host", Window{1200, -time.Minute}, Window{20, time.Second}, time.Second, true},
+		{"sub millisecond period", "host", Window{1200, time.Minute}, Window{1, time.Microsecond}, time.Second, true},
+		{"negative wait", "host", Window{1200, time.Minute}, Window{20, time.Second}, -time.Second, true},
+	}
+
+	for i := 0; i < len(testCases); i++ {
+		t.Run(testCases[i].name, func(t *testing.T) {
+			_, err := NewCheckedRateLimitConfig(testCases[i].host, testCases[i].sustained, testCases[i].burst, testCases[i].waitAfterHitLimit)
+
+			if (err != nil) != testCases[i].expectErr {
+				t.Errorf("Loop: %v. Expected error: %v, got: %v", i, testCases[i].expectErr, err)
+			}
+		})
+	}
+}
+
+func Test_CheckRequestWeight(t *testing.T) {
+	type testWeight struct {
+		config    RateLimitConfig
+		weight    int
+		expectErr bool
+	}
+
+	testCases := []testWeight{
+		{NewRateLimitConfig("host", 1200, 60, 20, 1, 0), 1, false},
+		{NewRateLimitConfig("host", 1200, 60, 20, 1, 0), 20, false},
+		{NewRateLimitConfig("host", 1200, 60, 20, 1, 0), 21, true},
+		{NewRateLimitConfig("host", 1200, 60, 20, 1, 0), 0, true},
+		{NewRateLimitConfig("host", 0, 0, 0, 0, 0), 5000, false},
+	}
+
+	for i := 0; i < len(testCases); i++ {
+		err := testCases[i].config.checkRequestWeight(testCases[i].weight)
+
+		if (err != nil) != testCases[i].expectErr {
+			t.Errorf("Loop: %v. Expected error: %v, got: %v", i, testCases[i].expectErr, err)
+		}
+	}
+}
+
 func Test_ReduceFraction(t *testing.T) {
 	type TestFraction struct {
 		numerator   int64
@@ -7,6 +7,7 @@ import (
 	"net/http"
 	url2 "net/url"
 	"strconv"
+	"sync"
 	"testing"
 	"time"
 
@@ -29,6 +30,27 @@ var (
 	)
 )
 
+//newTestConfig returns a config of requestLimit requests per second in burst mode, without a wait after hitting the limit
+func newTestConfig(host string, requestLimit int) RateLimitConfig {
+	config := NewRateLimitConfigFromWindows(host, Window{requestLimit, time.Second}, Window{requestLimit, time.Second}, 0)
+	config.SetBurstMode(true)
+	return config
+}
+
+//deleteTestHosts removes every key of the hosts, so each test starts from a clean state
+func deleteTestHosts(t *testing.T, hosts ...string) {
+	deletePrefixedTestHosts(t, "", hosts...)
+}
+
+//deletePrefixedTestHosts removes every key of the hosts under the key prefix
+func deletePrefixedTestHosts(t *testing.T, prefix string, hosts ...string) {
+	for _, host := range hosts {
+		if err := DeleteHost(pool, prefix, host); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
 func Test_CanMakeRequestTokenServer(t *testing.T) {
 	//handles creating new pool error
 	if err != nil {
@@ -90,12 +112,14 @@ func Test_CanMakeRequestWindowServer(t *testing.T) {
 	fmt.Print("done ")
 }
 
-func makeRequests(t *testing.T, limiter Limiter, id int, c chan<- string, url string) {
+func makeRequests(t *testing.T, limiter *Limiter, id int, c chan<- string, url string) {
 	numOfRequests := 3
 	for numOfRequests > 0 {
 		requestWeight := 1
 
-		limiter.WaitForRatelimit(requestWeight)
+		if err := limiter.WaitForRatelimit(requestWeight); err != nil {
+			t.Errorf("Error on WaitForRatelimit: %v. ", err)
+		}
 
 		statusCode, err := getStatusCode(url, requestWeight)
 		if err != nil {
@@ -115,8 +139,14 @@ func makeRequests(t *testing.T, limiter Limiter, id int, c chan<- string, url st
 				t.Errorf("Error on Request Finished: %v. ", err)
 			}
 			numOfRequests -= requestWeight
+		} else if statusCode == 419 {
+			if err := limiter.HitBan(requestWeight); err != nil {
+				t.Errorf("Error on HitBan: %v. ", err)
+			}
+
+			fmt.Printf("Routine: %v. %v. %v \n", id, statusCode, limiter.GetStatus())
 		} else {
-			if getUnixTimeMilliseconds()-limiter.status.lastErrorTime < 5000 {
+			if getUnixTimeMilliseconds()-limiter.GetStatus().lastErrorTime < 5000 {
 				t.Errorf("Multiple 429s too close together \n")
 			}
 
@@ -124,14 +154,14 @@ func makeRequests(t *testing.T, limiter Limiter, id int, c chan<- string, url st
 				t.Errorf("Error on HitRateLimit: %v. ", err)
 			}
 
-			fmt.Printf("Routine: %v. %v. %v, %v \n", id, statusCode, limiter.status, limiter.config)
+			fmt.Printf("Routine: %v. %v. %v \n", id, statusCode, limiter.GetStatus())
 		}
 
 		fmt.Print(".")
 	}
 
 	//if the time since the last error is less than one minute
-	if getUnixTimeMilliseconds()-limiter.status.lastErrorTime < 60*1000 {
+	if getUnixTimeMilliseconds()-limiter.GetStatus().lastErrorTime < 60*1000 {
 		go makeRequests(t, limiter, id, c, url)
 	} else {
 		c <- "done"
@@ -162,17 +192,17 @@ func Test_RequestCancelled(t *testing.T) {
 	testCases := []TestRequestStatus{
 		{
 			2,
-			Limiter{newRequestsStatus(0, 10, 0, 0), config, pool},
+			Limiter{status: newRequestsStatus(0, 10, 0, 0), config: config, pool: pool},
 			newRequestsStatus(0, 8, 0, 0),
 		},
 		{
 			1,
-			Limiter{newRequestsStatus(0, 3, 0, 0), config, pool},
+			Limiter{status: newRequestsStatus(0, 3, 0, 0), config: config, pool: pool},
 			newRequestsStatus(0, 2, 0, 0),
 		},
 		{
 			5,
-			Limiter{newRequestsStatus(0, 10, 0, 0), config, pool},
+			Limiter{status: newRequestsStatus(0, 10, 0, 0), config: config, pool: pool},
 			newRequestsStatus(0, 5, 0, 0),
 		},
 	}
@@ -180,7 +210,7 @@ func Test_RequestCancelled(t *testing.T) {
 	key := testCases[0].limiter.getStatusKey()
 
 	for i := 0; i < len(testCases); i++ {
-		l := testCases[i].limiter
+		l := &testCases[i].limiter
 
 		err := pool.Do(radix.WithConn(key, func(c radix.Conn) error {
 			err = pool.Do(radix.FlatCmd(nil, "HSET",
@@ -231,17 +261,17 @@ func Test_RequestFinished(t *testing.T) {
 	testCases := []TestRequestStatus{
 		{
 			2,
-			Limiter{newRequestsStatus(5, 2, 0, 0), config, pool},
+			Limiter{status: newRequestsStatus(5, 2, 0, 0), config: config, pool: pool},
 			newRequestsStatus(7, 0, 0, 0),
 		},
 		{
 			1,
-			Limiter{newRequestsStatus(0, 40, 0, 0), config, pool},
+			Limiter{status: newRequestsStatus(0, 40, 0, 0), config: config, pool: pool},
 			newRequestsStatus(1, 39, 0, 0),
 		},
 		{
 			5,
-			Limiter{newRequestsStatus(35, 5, 0, 0), config, pool},
+			Limiter{status: newRequestsStatus(35, 5, 0, 0), config: config, pool: pool},
 			newRequestsStatus(40, 0, 0, 0),
 		},
 	}
@@ -249,7 +279,7 @@ func Test_RequestFinished(t *testing.T) {
 	key := testCases[0].limiter.getStatusKey()
 
 	for i := 0; i < len(testCases); i++ {
-		l := testCases[i].limiter
+		l := &testCases[i].limiter
 
 		err := pool.Do(radix.WithConn(key, func(c radix.Conn) error {
 			err = pool.Do(radix.FlatCmd(nil, "HSET",
@@ -288,15 +318,15 @@ func Test_updateStatusFromDatabase(t *testing.T) {
 	config := NewRateLimitConfig("testHost1", 1, 1, 1, 1, 0)
 
 	testCases := []Limiter{
-		{newRequestsStatus(5, 2, 23564, 0), config, pool},
-		{newRequestsStatus(0, 40, 3454345, 0), config, pool},
-		{newRequestsStatus(35, 0, 266256, 0), config, pool},
+		{status: newRequestsStatus(5, 2, 23564, 0), config: config, pool: pool},
+		{status: newRequestsStatus(0, 40, 3454345, 0), config: config, pool: pool},
+		{status: newRequestsStatus(35, 0, 266256, 0), config: config, pool: pool},
 	}
 
 	key := testCases[0].getStatusKey()
 
 	for i := 0; i < len(testCases); i++ {
-		l := testCases[i]
+		l := &testCases[i]
 		newLimiter, err := NewLimiter(config, pool)
 
 		err = pool.Do(radix.WithConn(key, func(c radix.Conn) error {
@@ -328,33 +358,57 @@ func Test_updateStatusFromDatabase(t *testing.T) {
 	}
 }
 
+func Test_InvalidConfigAndWeight(t *testing.T) {
+	if _, err := NewLimiter(NewRateLimitConfig("", 20, 1, 20, 1, 0), pool); err == nil {
+		t.Errorf("Expected an error for a config without a host name")
+	}
+
+	if _, err := NewLimiter(NewRateLimitConfig("testHost2", -20, 1, 0, 0, 0), pool); err == nil {
+		t.Errorf("Expected an error for a config with a negative limit")
+	}
+
+	limiter, err := NewLimiter(NewRateLimitConfig("testHost2", 20, 1, 20, 1, 0), pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	canMake, wait, err := limiter.CanMakeRequest(21)
+	if err == nil || canMake || wait != 0 {
+		t.Errorf("Expected an error for a request weight larger than the limit, got: %v, %v, %v", canMake, wait, err)
+	}
+
+	if err := limiter.WaitForRatelimit(21); err == nil {
+		t.Errorf("Expected WaitForRatelimit to return an error instead of waiting forever")
+	}
+}
+
 func Test_GetStatus(t *testing.T) {
 
 	testCases := []Limiter{
 		{
-			newRequestsStatus(10, 45, getUnixTimeMilliseconds(), 42350232),
-			NewRateLimitConfig("host1", 45, 3, 452, 4, 0),
-			pool,
+			status: newRequestsStatus(10, 45, getUnixTimeMilliseconds(), 42350232),
+			config: NewRateLimitConfig("host1", 45, 3, 452, 4, 0),
+			pool:   pool,
 		},
 		{
-			newRequestsStatus(52, 85, 23542636, 34534),
-			NewRateLimitConfig("host2", 25453, 2343, 234, 3243, 0),
-			pool,
+			status: newRequestsStatus(52, 85, 23542636, 34534),
+			config: NewRateLimitConfig("host2", 25453, 2343, 234, 3243, 0),
+			pool:   pool,
 		},
 		{
-			newRequestsStatus(0, 0, 0, 0),
-			NewRateLimitConfig("host3", 0, 0, 0, 0, 0),
-			pool,
+			status: newRequestsStatus(0, 0, 0, 0),
+			config: NewRateLimitConfig("host3", 0, 0, 0, 0, 0),
+			pool:   pool,
 		},
 		{
-			newRequestsStatus(1, 23, 324, 423362),
-			NewRateLimitConfig("host4", 23523, 324, 23, 1, 0),
-			pool,
+			status: newRequestsStatus(1, 23, 324, 423362),
+			config: NewRateLimitConfig("host4", 23523, 324, 23, 1, 0),
+			pool:   pool,
 		},
 		{
-			newRequestsStatus(120, 32, 455635435, 1246564566),
-			NewRateLimitConfig("host5", 1200, 60, 20, 10, 0),
-			pool,
+			status: newRequestsStatus(120, 32, 455635435, 1246564566),
+			config: NewRateLimitConfig("host5", 1200, 60, 20, 10, 0),
+			pool:   pool,
 		},
 	}
 
@@ -364,3 +418,200 @@ func Test_GetStatus(t *testing.T) {
 		}
 	}
 }
+
+func Test_UpdateConfig(t *testing.T) {
+	deleteTestHosts(t, "updateConfigHost")
+
+	first, err := NewLimiter(newTestConfig("updateConfigHost", 10), pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := NewLimiter(newTestConfig("updateConfigHost", 10), pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := second.CanMakeRequest(1); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := first.UpdateConfig(newTestConfig("updateConfigHost", 4)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := second.CanMakeRequest(1); err != nil {
+		t.Fatal(err)
+	}
+	if second.config.requestLimit != 4 {
+		t.Errorf("Expected the updated limit of 4 after one decision, got: %v", second.config.requestLimit)
+	}
+
+	//a config edited directly in redis is loaded once its version is incremented
+	err = pool.Do(radix.Pipeline(
+		radix.FlatCmd(nil, "HSET", "config:updateConfigHost", limit, 6, timeBetweenRequests, 166),
+		radix.FlatCmd(nil, "HINCRBY", "config:updateConfigHost", configVersion, 1),
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := second.CanMakeRequest(1); err != nil {
+		t.Fatal(err)
+	}
+	if second.config.requestLimit != 6 {
+		t.Errorf("Expected the edited limit of 6 after one decision, got: %v", second.config.requestLimit)
+	}
+
+	if err := first.UpdateConfig(newTestConfig("otherHost", 4)); err == nil {
+		t.Errorf("Expected an error for a config with a different host")
+	}
+}
+
+func Test_ConfigWithoutVersion(t *testing.T) {
+	deleteTestHosts(t, "noVersionHost")
+
+	//a config learned by a process that never incremented the version
+	err := pool.Do(radix.FlatCmd(nil, "HSET", "config:noVersionHost", limit, 4, timePeriodMs, 1000, timeBetweenRequests, 250))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	limiter, err := NewLimiter(newTestConfig("noVersionHost", 10), pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := limiter.CanMakeRequest(1); err != nil {
+		t.Fatal(err)
+	}
+
+	if limiter.config.requestLimit != 4 {
+		t.Errorf("Expected the limit of 4 saved for the host to be loaded, got: %v", limiter.config.requestLimit)
+	}
+}
+
+func Test_UpdateConfigSettings(t *testing.T) {
+	deleteTestHosts(t, "updateSettingsHost")
+
+	first, err := NewLimiter(newTestConfig("updateSettingsHost", 10), pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//a limiter created with other settings uses the ones already saved for the host
+	local := newTestConfig("updateSettingsHost", 10)
+	local.SetBurstMode(false)
+	second, err := NewLimiter(local, pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := second.CanMakeRequest(1); err != nil {
+		t.Fatal(err)
+	}
+	if !second.config.burstMode {
+		t.Errorf("Expected the burst mode saved for the host, got: %v", second.config.burstMode)
+	}
+
+	updated := newTestConfig("updateSettingsHost", 10)
+	updated.SetBurstMode(false)
+	updated.SetBanPolicy(BanPolicy{Cooldown: time.Minute, Reduction: 0.5})
+	updated.SetRecoveryProbes(2)
+	if err := first.UpdateConfig(updated); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := second.CanMakeRequest(1); err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := deep.Equal(second.config.getSettings(), updated.getSettings()); diff != nil {
+		t.Error(diff)
+	}
+}
+
+func Test_HitRateLimitReloadsConfig(t *testing.T) {
+	deleteTestHosts(t, "reloadConfigHost")
+
+	first, err := NewLimiter(newTestConfig("reloadConfigHost", 10), pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := NewLimiter(newTestConfig("reloadConfigHost", 10), pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := second.CanMakeRequest(1); err != nil {
+		t.Fatal(err)
+	}
+
+	updated := newTestConfig("reloadConfigHost", 6)
+	updated.SetRecoveryProbes(2)
+	if err := first.UpdateConfig(updated); err != nil {
+		t.Fatal(err)
+	}
+
+	//the 429 is reduced from the updated config, not the one the second limiter last loaded
+	if err := second.HitRateLimit(1); err != nil {
+		t.Fatal(err)
+	}
+
+	var values []string
+	if err := pool.Do(radix.Cmd(&values, "HMGET", "config:reloadConfigHost", limit, baseLimit)); err != nil {
+		t.Fatal(err)
+	}
+
+	if values[0] != "5" || values[1] != "6" {
+		t.Errorf("Expected a limit of 5 from the updated limit of 6, got: %v", values)
+	}
+
+	if second.config.recoveryProbes != 2 {
+		t.Errorf("Expected the updated settings to be kept, got %v recovery probes", second.config.recoveryProbes)
+	}
+}
+
+func Test_SharedLimiter(t *testing.T) {
+	deleteTestHosts(t, "sharedHost")
+
+	limiter, err := NewLimiter(newTestConfig("sharedHost", 10), pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	granted := 0
+
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			canMake, _, err := limiter.CanMakeRequest(1)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			status := limiter.GetStatus()
+			if status.requests+status.pendingRequests > 10 {
+				t.Errorf("Expected a consistent status, got: %v", status)
+			}
+
+			if canMake {
+				mu.Lock()
+				granted++
+				mu.Unlock()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if granted != 10 {
+		t.Errorf("Expected 10 requests to be granted, got: %v", granted)
+	}
+}
@@ -13,6 +13,11 @@ type RequestsStatus struct {
 	pendingRequests int //number of requests that have started but have not completed
 	firstRequest    int64
 	lastErrorTime   int64
+	bans            int   //number of bans in a row, each one escalating the cooldown
+	banUntil        int64 //time in milliseconds until which no requests are approved because of a ban
+	probes          int   //successful probe requests still needed after a cooldown, 0 or less if none
+	probing         int   //probe requests that have been granted but have not completed
+	created         int64 //time in milliseconds the status of the host was first saved
 }
 
 const (
@@ -20,21 +25,28 @@ const (
 	pendingRequests = "pendingRequests"
 	firstRequest    = "firstRequest"
 	lastErrorTime   = "lasterror"
+	bans            = "bans"
+	banUntil        = "banUntil"
+	probes          = "probes"
+	probing         = "probing"
+	created         = "created"
 )
 
-//key convention redis: struct:host
+//key convention redis: struct:host, or prefix:struct:host with WithKeyPrefix
 //example: status:com.binance.api
-//example: config:com.binance.api
+//example: staging:config:com.binance.api
 
 //updateStatusFromDatabase gets the current request status information from the database and updates the struct
 func (r *RequestsStatus) updateStatusFromDatabase(c radix.Conn, key string) error {
 	var values []string
-	err := c.Do(radix.Cmd(&values, "HVALS", key))
+	//HMGET returns the fields in the order they are asked for, unlike HVALS
+	err := c.Do(radix.Cmd(&values, "HMGET", key, requests, pendingRequests, firstRequest, lastErrorTime, bans, banUntil, probes, created, probing))
 	if err != nil {
 		return err
 	}
 
-	if len(values) != 4 {
+	//the status does not exist in the database yet
+	if len(values) != 9 || values[0] == "" {
 		return nil
 	}
 
@@ -42,8 +54,19 @@ func (r *RequestsStatus) updateStatusFromDatabase(c radix.Conn, key string) erro
 	pending, _ := strconv.Atoi(values[1])
 	first, _ := strconv.ParseInt(values[2], 10, 64)
 	last, _ := strconv.ParseInt(values[3], 10, 64)
+	//a host that was never banned has no ban fields
+	bans, _ := strconv.Atoi(values[4])
+	until, _ := strconv.ParseInt(values[5], 10, 64)
+	probes, _ := strconv.Atoi(values[6])
+	createdAt, _ := strconv.ParseInt(values[7], 10, 64)
+	inFlight, _ := strconv.Atoi(values[8])
 
 	*r = newRequestsStatus(requests, pending, first, last)
+	r.bans = bans
+	r.banUntil = until
+	r.probes = probes
+	r.created = createdAt
+	r.probing = inFlight
 	return nil
 }
 
@@ -51,11 +74,23 @@ func (r *RequestsStatus) updateStatusFromDatabase(c radix.Conn, key string) erro
 //returns true, 0 if request can be made
 //returns false and the number of milliseconds to wait if a request cannot be made
 func (r *RequestsStatus) canMakeRequestLogic(requestWeight int, config RateLimitConfig) (bool, int64) {
-	now := getUnixTimeMilliseconds()
+	return r.canMakeRequestAt(requestWeight, config, getUnixTimeMilliseconds())
+}
+
+//canMakeRequestAt is canMakeRequestLogic at the given time in milliseconds
+func (r *RequestsStatus) canMakeRequestAt(requestWeight int, config RateLimitConfig, now int64) (bool, int64) {
+	if now < r.banUntil {
+		return false, r.banUntil - now
+	}
 
 	timeSinceLastError := now - r.lastErrorTime
-	if timeSinceLastError < config.waitAfterHitLimit*1000 {
-		return false, config.timePeriod - timeSinceLastError
+	if timeSinceLastError < config.waitAfterHitLimit {
+		return false, config.waitAfterHitLimit - timeSinceLastError
+	}
+
+	//only probe requests are approved until the host has recovered from the cooldown
+	if canProbe, wait := r.probeAllows(requestWeight, config); !canProbe {
+		return false, wait
 	}
 
 	if r.isInPeriod(now, config) {
@@ -64,8 +99,8 @@ func (r *RequestsStatus) canMakeRequestLogic(requestWeight int, config RateLimit
 			return false, r.timeUntilEndOfPeriod(now, config)
 		}
 
-		if r.hasEnoughTimePassed(now, config) {
-			r.pendingRequests += requestWeight
+		if config.burstMode || r.hasEnoughTimePassed(now, config) {
+			r.grant(requestWeight)
 			return true, 0
 		}
 
@@ -76,15 +111,23 @@ func (r *RequestsStatus) canMakeRequestLogic(requestWeight int, config RateLimit
 	r.requests = 0
 	r.firstRequest = now
 
-	r.pendingRequests += requestWeight
+	r.grant(requestWeight)
 	return true, 0
 }
 
+//grant adds an approved request to the pending requests, and to the probes in flight if the host is recovering
+func (r *RequestsStatus) grant(requestWeight int) {
+	r.pendingRequests += requestWeight
+	if r.isRecovering() {
+		r.probing += requestWeight
+	}
+}
+
 //isInPeriod checks if the current request falls in the time frame of the period
 func (r *RequestsStatus) isInPeriod(currentTime int64, hostConfig RateLimitConfig) bool {
 	timeSincePeriodStart := currentTime - r.firstRequest
-	//								converts seconds to milliseconds
-	return timeSincePeriodStart < hostConfig.timePeriod*1000 && timeSincePeriodStart >= 0
+
+	return timeSincePeriodStart < hostConfig.timePeriod && timeSincePeriodStart >= 0
 }
 
 //willHitLimit checks if the current request will hit the rate limit
@@ -98,8 +141,7 @@ func (r *RequestsStatus) willHitLimit(requestWeight int, host RateLimitConfig) b
 
 //timeUntilEndOfPeriod calculates the time in milliseconds until the end of the period
 func (r *RequestsStatus) timeUntilEndOfPeriod(currentTime int64, host RateLimitConfig) (millisecondsToWait int64) {
-	// 											converts from seconds to milliseconds
-	endOfPeriod := r.firstRequest + (host.timePeriod * 1000)
+	endOfPeriod := r.firstRequest + host.timePeriod
 
 	return endOfPeriod - currentTime
 }
@@ -127,5 +169,10 @@ func getUnixTimeMilliseconds() int64 {
 }
 
 func newRequestsStatus(requests int, pending int, firstRequest int64, lastErrorTime int64) RequestsStatus {
-	return RequestsStatus{requests, pending, firstRequest, lastErrorTime}
+	return RequestsStatus{
+		requests:        requests,
+		pendingRequests: pending,
+		firstRequest:    firstRequest,
+		lastErrorTime:   lastErrorTime,
+	}
 }
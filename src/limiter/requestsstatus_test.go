@@ -2,6 +2,7 @@ package limiter
 
 import (
 	"testing"
+	"time"
 
 	"github.com/go-test/deep"
 )
@@ -83,6 +84,77 @@ func Test_CanMakeRequestLogic(t *testing.T) {
 	}
 }
 
+func Test_CanMakeRequestLogicBurstMode(t *testing.T) {
+	host := NewRateLimitConfig("test_host_1", 1200, 60, 20, 1, 0)
+	host.SetBurstMode(true)
+
+	type HostStatusTest struct {
+		name                   string
+		requestWeight          int
+		status                 RequestsStatus
+		expectedStatus         RequestsStatus
+		expectedCanMakeRequest bool
+	}
+
+	now := getUnixTimeMilliseconds()
+
+	testCases := []HostStatusTest{
+		{
+			"in period, will not hit limit, requests made back to back",
+			1,
+			newRequestsStatus(10, 3, now, 0),
+			newRequestsStatus(10, 4, now, 0),
+			true,
+		},
+		{
+			"in period, fills the rest of the limit at once",
+			7,
+			newRequestsStatus(10, 3, now, 0),
+			newRequestsStatus(10, 10, now, 0),
+			true,
+		},
+		{
+			"in period, will hit limit",
+			1,
+			newRequestsStatus(15, 5, now, 0),
+			newRequestsStatus(15, 5, now, 0),
+			false,
+		},
+	}
+
+	for i := 0; i < len(testCases); i++ {
+		t.Run(testCases[i].name, func(t *testing.T) {
+			status := testCases[i].status
+			canMake, _ := status.canMakeRequestLogic(testCases[i].requestWeight, host)
+
+			if canMake != testCases[i].expectedCanMakeRequest {
+				t.Errorf("Loop: %v. Expected ability to make request: %v, got: %v", i, testCases[i].expectedCanMakeRequest, canMake)
+			}
+
+			if status != testCases[i].expectedStatus {
+				t.Errorf("Loop: %v. Expected status %v, got: %v", i, testCases[i].expectedStatus, status)
+			}
+		})
+	}
+}
+
+func Test_CanMakeRequestLogicAfterHitLimit(t *testing.T) {
+	host := NewRateLimitConfigFromWindows("test_host_1", Window{20, time.Second}, Window{20, time.Second}, 3*time.Second)
+
+	now := getUnixTimeMilliseconds()
+	status := newRequestsStatus(0, 0, now, now-1000)
+
+	canMake, wait := status.canMakeRequestLogic(1, host)
+	if canMake {
+		t.Errorf("Expected request to be denied while waiting after hitting the limit")
+	}
+
+	//the remaining two seconds of waitAfterHitLimit, give or take the time the test takes
+	if wait > 2000 || wait < 1980 {
+		t.Errorf("Expected to wait about 2000ms, got: %v", wait)
+	}
+}
+
 func Test_IsInSustainedPeriod(t *testing.T) {
 	hosts := []RateLimitConfig{
 		NewRateLimitConfig("test_host_1", 20, 60, 20, 1, 0),
@@ -101,7 +173,7 @@ func Test_IsInSustainedPeriod(t *testing.T) {
 	testCases := []HostStatusTest{
 		{
 			hosts[0],
-			newRequestsStatus(0, 0, now-(hosts[0].timePeriod*1000), 0),
+			newRequestsStatus(0, 0, now-hosts[0].timePeriod, 0),
 			false,
 		},
 		{
@@ -111,27 +183,27 @@ func Test_IsInSustainedPeriod(t *testing.T) {
 		},
 		{
 			hosts[0],
-			newRequestsStatus(0, 0, now-(hosts[0].timePeriod*1000), 0),
+			newRequestsStatus(0, 0, now-hosts[0].timePeriod, 0),
 			false,
 		},
 		{
 			hosts[0],
-			newRequestsStatus(0, 0, now-(hosts[0].timePeriod*1000)-100, 0),
+			newRequestsStatus(0, 0, now-hosts[0].timePeriod-100, 0),
 			false,
 		},
 		{
 			hosts[1],
-			newRequestsStatus(0, 0, now-(hosts[1].timePeriod*1000)-50, 0),
+			newRequestsStatus(0, 0, now-hosts[1].timePeriod-50, 0),
 			false,
 		},
 		{
 			hosts[1],
-			newRequestsStatus(0, 0, now-(hosts[1].timePeriod*1000)/7, 0),
+			newRequestsStatus(0, 0, now-hosts[1].timePeriod/7, 0),
 			true,
 		},
 		{
 			hosts[2],
-			newRequestsStatus(0, 0, now-(hosts[0].timePeriod*1000)/2, 0),
+			newRequestsStatus(0, 0, now-hosts[0].timePeriod/2, 0),
 			true,
 		},
 	}
@@ -0,0 +1,55 @@
+package limiter
+
+import "context"
+
+//Reservation is delivered by Reserve once a request has been granted, or when waiting for it failed.
+type Reservation struct {
+	Weight int   //request weight that was reserved
+	Err    error //error that stopped the wait, in which case nothing was reserved
+}
+
+//Reserve waits for a request in the background, the same way WaitForRatelimit does, so waiting
+//for the limiter can be mixed with other events in a select. The returned channel delivers one
+//Reservation once the request is granted and is then closed. If the context is done first, the
+//channel is closed without delivering anything.
+//
+//The Reservation is only handed over once it is received, so a request granted while nobody is
+//listening is given back as soon as the context is done. Every call should get a context of its own
+//that is cancelled once the select is over, otherwise a request granted after another case won stays
+//reserved for as long as the context lives.
+//
+//	reserveCtx, cancel := context.WithCancel(ctx)
+//	select {
+//	case r, ok := <-limiter.Reserve(reserveCtx, 1):
+//		//make the request if ok and r.Err is nil
+//	case msg := <-messages:
+//		//handle the message
+//	}
+//	//gives back the request if it is granted after the message won
+//	cancel()
+//RequestSuccessful, HitRateLimit or RequestCancelled must be called for every Reservation without an Err.
+func (l *Limiter) Reserve(ctx context.Context, requestWeight int, opts ...AcquireOption) <-chan Reservation {
+	//unbuffered so a Reservation is never left in the channel after the caller stopped listening
+	reservations := make(chan Reservation)
+
+	go func() {
+		defer close(reservations)
+
+		err := l.wait(ctx, requestWeight, opts)
+		if err != nil && err == ctx.Err() {
+			return
+		}
+
+		select {
+		case reservations <- Reservation{Weight: requestWeight, Err: err}:
+		case <-ctx.Done():
+			//the caller stopped listening before the request was handed over, so it is given back.
+			//there is no one left to report an error to.
+			if err == nil {
+				l.RequestCancelled(requestWeight, opts...)
+			}
+		}
+	}()
+
+	return reservations
+}
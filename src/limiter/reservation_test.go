@@ -0,0 +1,78 @@
+package limiter
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func Test_Reserve(t *testing.T) {
+	deleteTestHosts(t, "reserveHost")
+
+	limiter, err := NewLimiter(newTestConfig("reserveHost", 1), pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case r, ok := <-limiter.Reserve(context.Background(), 1):
+		if !ok || r.Err != nil || r.Weight != 1 {
+			t.Errorf("Expected a reservation of weight 1, got: %v, %v", r, ok)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Expected the first request to be granted right away")
+	}
+
+	//the limit of one request per second is used up
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	select {
+	case r, ok := <-limiter.Reserve(ctx, 1):
+		if ok {
+			t.Errorf("Expected the channel to be closed when the context is done, got: %v", r)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("Expected the channel to be closed when the context is done")
+	}
+
+	if pending := getPendingRequests(t, "reserveHost"); pending != 1 {
+		t.Errorf("Expected only the first reservation to be pending, got: %v", pending)
+	}
+}
+
+func Test_ReserveError(t *testing.T) {
+	limiter, err := NewLimiter(newTestConfig("reserveHost2", 1), pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, ok := <-limiter.Reserve(context.Background(), 2)
+	if !ok || r.Err == nil {
+		t.Errorf("Expected a reservation with an error for a weight larger than the limit, got: %v, %v", r, ok)
+	}
+}
+
+func Test_ReserveNotReceived(t *testing.T) {
+	deleteTestHosts(t, "reserveHost3")
+
+	limiter, err := NewLimiter(newTestConfig("reserveHost3", 1), pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	reservations := limiter.Reserve(ctx, 1)
+
+	//the request is granted, but the caller stops listening before reading it
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+
+	if _, ok := <-reservations; ok {
+		t.Errorf("Expected the channel to be closed without a reservation")
+	}
+
+	if pending := getPendingRequests(t, "reserveHost3"); pending != 0 {
+		t.Errorf("Expected the unread reservation to be given back, got %v pending requests", pending)
+	}
+}
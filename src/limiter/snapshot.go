@@ -0,0 +1,157 @@
+package limiter
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mediocregopher/radix/v3"
+)
+
+//Snapshot keeps a copy of the config of every host in a local JSON file, so the limits learned
+//from 429s and bans survive a redis database that is flushed or replaced.
+type Snapshot struct {
+	path   string
+	pool   *radix.Pool
+	prefix string     //key prefix of the hosts in the snapshot
+	mu     sync.Mutex //keeps saves from writing the file at the same time
+}
+
+//snapshotFile is the content of the JSON file of a Snapshot
+type snapshotFile struct {
+	Hosts map[string]map[string]string `json:"hosts"` //config hash of every host by host name
+}
+
+//NewSnapshot returns a Snapshot of the configs under the key prefix in the redis database of the pool,
+//saved to the file at path. The prefix is empty for limiters created without WithKeyPrefix.
+func NewSnapshot(pool *radix.Pool, prefix string, path string) *Snapshot {
+	return &Snapshot{
+		path:   path,
+		pool:   pool,
+		prefix: prefix,
+	}
+}
+
+//WithSnapshot seeds the config of the host from the Snapshot when it is missing from redis, before
+//falling back to the config given to NewLimiter. A host that is not in the file uses the given config.
+//NewLimiter returns an error if the Snapshot is for another key prefix than the Limiter.
+func WithSnapshot(s *Snapshot) Option {
+	return func(l *Limiter) {
+		l.snapshot = s
+	}
+}
+
+//Run saves the Snapshot every interval until the context is done. A failed save is tried again at
+//the next interval. Once the context is done, Run returns the error of the last save, if it failed.
+//
+//	go snapshot.Run(ctx, time.Minute)
+func (s *Snapshot) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var err error
+	for {
+		select {
+		case <-ctx.Done():
+			return err
+		case <-ticker.C:
+			err = s.Save()
+		}
+	}
+}
+
+//Save writes the config of every host in redis to the file of the Snapshot.
+//The file is replaced at once, so a crash during a save keeps the previous snapshot.
+func (s *Snapshot) Save() error {
+	if err := validateKeyPrefix(s.prefix); err != nil {
+		return err
+	}
+
+	hosts, err := readConfigs(s.pool, s.prefix)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(snapshotFile{Hosts: hosts}, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	temp := s.path + ".tmp"
+	if err := ioutil.WriteFile(temp, data, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(temp, s.path)
+}
+
+//config returns the saved config hash of the host, or nil if there is none
+func (s *Snapshot) config(host string) (map[string]string, error) {
+	s.mu.Lock()
+	data, err := ioutil.ReadFile(s.path)
+	s.mu.Unlock()
+
+	//nothing has been saved yet
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var file snapshotFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+
+	return file.Hosts[host], nil
+}
+
+//readConfigs returns the config hash of every host under the key prefix by host name
+func readConfigs(pool *radix.Pool, prefix string) (map[string]map[string]string, error) {
+	hosts, err := scanHosts(pool, prefix, configKind)
+	if err != nil {
+		return nil, err
+	}
+
+	configs := make(map[string]map[string]string)
+	for _, host := range hosts {
+		var config map[string]string
+		if err := pool.Do(radix.Cmd(&config, "HGETALL", formatKey(prefix, configKind, host))); err != nil {
+			return nil, err
+		}
+
+		//the key may have been deleted since it was scanned
+		if len(config) > 0 {
+			configs[host] = config
+		}
+	}
+
+	return configs, nil
+}
+
+//scanHosts returns the name of every host with a key of the given kind under the key prefix
+func scanHosts(pool *radix.Pool, prefix string, kind string) ([]string, error) {
+	var hosts []string
+	start := kindPrefix(prefix, kind)
+	scanner := radix.NewScanner(pool, radix.ScanOpts{Command: "SCAN", Pattern: start + "*"})
+
+	var key string
+	for scanner.Next(&key) {
+		hosts = append(hosts, strings.TrimPrefix(key, start))
+	}
+
+	if err := scanner.Close(); err != nil {
+		return nil, err
+	}
+
+	return hosts, nil
+}
@@ -0,0 +1,82 @@
+package limiter
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mediocregopher/radix/v3"
+)
+
+func Test_Snapshot(t *testing.T) {
+	deleteTestHosts(t, "snapshotHost", "snapshotNewHost")
+
+	dir, err := ioutil.TempDir("", "snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	snapshot := NewSnapshot(pool, "", filepath.Join(dir, "configs.json"))
+
+	//nothing has been saved yet, so the given config is used
+	limiter, err := NewLimiter(newTestConfig("snapshotHost", 10), pool, WithSnapshot(snapshot))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 4; i++ {
+		if err := limiter.HitRateLimit(1); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := snapshot.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	//redis loses the learned limit, and the status starts over in an earlier period
+	err = pool.Do(radix.Pipeline(
+		radix.Cmd(nil, "DEL", "config:snapshotHost"),
+		radix.FlatCmd(nil, "HSET", "status:snapshotHost", requests, 0, pendingRequests, 0, firstRequest, 0, lastErrorTime, 0),
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type TestSnapshot struct {
+		host    string
+		granted int
+	}
+
+	testCases := []TestSnapshot{
+		{"snapshotHost", 6},     //seeded with the limit learned from the 429s
+		{"snapshotNewHost", 10}, //not in the snapshot
+	}
+
+	for i := 0; i < len(testCases); i++ {
+		limiter, err := NewLimiter(newTestConfig(testCases[i].host, 10), pool, WithSnapshot(snapshot))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		granted, _, err := limiter.AcquireN(context.Background(), 10, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if granted != testCases[i].granted {
+			t.Errorf("Loop: %v. Expected %v requests to be granted, got: %v", i, testCases[i].granted, granted)
+		}
+	}
+}
+
+func Test_SnapshotOfAnotherPrefix(t *testing.T) {
+	snapshot := NewSnapshot(pool, "production", filepath.Join(os.TempDir(), "configs.json"))
+
+	if _, err := NewLimiter(newTestConfig("snapshotHost", 10), pool, WithKeyPrefix("staging"), WithSnapshot(snapshot)); err == nil {
+		t.Errorf("Expected an error for a snapshot of another key prefix")
+	}
+}
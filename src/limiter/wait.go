@@ -0,0 +1,98 @@
+package limiter
+
+import (
+	"errors"
+	"math/rand"
+	"time"
+)
+
+//ErrTooManyAttempts is returned by WaitForRatelimit when the request was denied more times
+//than the MaxAttempts of the WaitPolicy of the Limiter.
+var ErrTooManyAttempts = errors.New("request was denied too many times")
+
+//Backoff decides how long a waiter sleeps after its request was denied. attempt is the number of
+//times the request has been denied so far, starting at 1, and hint is the wait time returned by CanMakeRequest.
+type Backoff interface {
+	Delay(attempt int, hint time.Duration) time.Duration
+}
+
+//HintBackoff sleeps for exactly the wait time returned by CanMakeRequest. It is the default Backoff.
+type HintBackoff struct{}
+
+//Delay returns the hint
+func (HintBackoff) Delay(attempt int, hint time.Duration) time.Duration {
+	return hint
+}
+
+//ExponentialBackoff sleeps at least Base after the first denied attempt, doubling with every attempt
+//up to Max. It never sleeps less than the wait time returned by CanMakeRequest, which keeps waiters
+//that are denied with short wait times because of contention from polling redis in a tight loop.
+type ExponentialBackoff struct {
+	Base time.Duration
+	Max  time.Duration
+}
+
+//Delay returns the larger of the hint and the exponential delay of the attempt
+func (b ExponentialBackoff) Delay(attempt int, hint time.Duration) time.Duration {
+	delay := b.Base
+	for i := 1; i < attempt && delay < b.Max; i++ {
+		delay *= 2
+	}
+
+	if delay > b.Max {
+		delay = b.Max
+	}
+
+	if hint > delay {
+		return hint
+	}
+
+	return delay
+}
+
+//WaitPolicy controls how WaitForRatelimit sleeps between attempts.
+//The zero value sleeps for the wait time returned by CanMakeRequest and never gives up.
+type WaitPolicy struct {
+	//Jitter adds a random delay of up to Jitter times the sleep time, so waiters that were denied
+	//at the same time do not all try again at the same instant. 0.1 adds up to 10%.
+	Jitter float64
+	//MaxAttempts is how many times a request can be denied before WaitForRatelimit
+	//returns ErrTooManyAttempts. 0 allows any number of attempts.
+	MaxAttempts int
+	//Backoff decides how long to sleep after a denied attempt. nil uses HintBackoff.
+	Backoff Backoff
+}
+
+//WithWaitPolicy sets how WaitForRatelimit sleeps between attempts.
+//
+//	limiter, err := NewLimiter(config, pool, WithWaitPolicy(WaitPolicy{Jitter: 0.2, MaxAttempts: 10}))
+func WithWaitPolicy(policy WaitPolicy) Option {
+	return func(l *Limiter) {
+		l.waitPolicy = policy
+	}
+}
+
+//delay returns how long to sleep after the given denied attempt, or ErrTooManyAttempts if the
+//policy does not allow another attempt.
+func (p WaitPolicy) delay(attempt int, hint time.Duration) (time.Duration, error) {
+	if p.MaxAttempts > 0 && attempt >= p.MaxAttempts {
+		return 0, ErrTooManyAttempts
+	}
+
+	var backoff Backoff = HintBackoff{}
+	if p.Backoff != nil {
+		backoff = p.Backoff
+	}
+
+	return p.addJitter(backoff.Delay(attempt, hint)), nil
+}
+
+//addJitter adds a random part of the jitter to the sleep time. Only positive jitter is added,
+//since waking up before the wait time is over would only be denied again.
+func (p WaitPolicy) addJitter(d time.Duration) time.Duration {
+	if p.Jitter <= 0 || d <= 0 {
+		return d
+	}
+
+	return d + time.Duration(rand.Float64()*p.Jitter*float64(d))
+}
@@ -0,0 +1,87 @@
+package limiter
+
+import (
+	"testing"
+	"time"
+)
+
+//constantBackoff retries after the same delay no matter what CanMakeRequest returned
+type constantBackoff time.Duration
+
+func (b constantBackoff) Delay(attempt int, hint time.Duration) time.Duration {
+	return time.Duration(b)
+}
+
+func Test_ExponentialBackoff(t *testing.T) {
+	backoff := ExponentialBackoff{Base: 10 * time.Millisecond, Max: 100 * time.Millisecond}
+
+	type TestBackoff struct {
+		attempt  int
+		hint     time.Duration
+		expected time.Duration
+	}
+
+	testCases := []TestBackoff{
+		{1, 0, 10 * time.Millisecond},
+		{2, 0, 20 * time.Millisecond},
+		{4, 5 * time.Millisecond, 80 * time.Millisecond},
+		{5, 0, 100 * time.Millisecond},
+		{50, 0, 100 * time.Millisecond},
+		{1, time.Second, time.Second},
+	}
+
+	for i := 0; i < len(testCases); i++ {
+		delay := backoff.Delay(testCases[i].attempt, testCases[i].hint)
+		if delay != testCases[i].expected {
+			t.Errorf("Loop: %v. Expected %v, got: %v", i, testCases[i].expected, delay)
+		}
+	}
+}
+
+func Test_WaitPolicyDelay(t *testing.T) {
+	policy := WaitPolicy{Jitter: 0.5, MaxAttempts: 3}
+
+	for attempt := 1; attempt < 3; attempt++ {
+		delay, err := policy.delay(attempt, 100*time.Millisecond)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if delay < 100*time.Millisecond || delay > 150*time.Millisecond {
+			t.Errorf("Attempt: %v. Expected a delay between 100ms and 150ms, got: %v", attempt, delay)
+		}
+	}
+
+	if _, err := policy.delay(3, 100*time.Millisecond); err != ErrTooManyAttempts {
+		t.Errorf("Expected ErrTooManyAttempts after the last attempt, got: %v", err)
+	}
+
+	if delay, err := (WaitPolicy{}).delay(1000, time.Second); err != nil || delay != time.Second {
+		t.Errorf("Expected the zero policy to sleep for the hint, got: %v, %v", delay, err)
+	}
+}
+
+func Test_WaitForRatelimitMaxAttempts(t *testing.T) {
+	deleteTestHosts(t, "maxAttemptsHost")
+
+	config := NewRateLimitConfigFromWindows("maxAttemptsHost", Window{1, 10 * time.Second}, Window{1, 10 * time.Second}, 0)
+	policy := WaitPolicy{MaxAttempts: 3, Backoff: constantBackoff(10 * time.Millisecond)}
+
+	limiter, err := NewLimiter(config, pool, WithWaitPolicy(policy))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := limiter.WaitForRatelimit(1); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	if err := limiter.WaitForRatelimit(1); err != ErrTooManyAttempts {
+		t.Errorf("Expected ErrTooManyAttempts, got: %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected the backoff to be used instead of the wait time, waited: %v", elapsed)
+	}
+}
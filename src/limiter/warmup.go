@@ -0,0 +1,114 @@
+package limiter
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+//WarmUpCurve is how the request limit grows during a warm-up.
+type WarmUpCurve int
+
+const (
+	//WarmUpLinear grows the request limit by the same number of requests over the whole warm-up.
+	WarmUpLinear WarmUpCurve = iota
+	//WarmUpExponential grows the request limit slowly at first and quickly at the end of the warm-up.
+	WarmUpExponential
+)
+
+//WarmUp ramps up the request limit of a host instead of allowing the whole limit at once.
+type WarmUp struct {
+	//Duration is how long it takes to reach the full request limit.
+	Duration time.Duration
+	//Floor is the share of the request limit allowed at the start of the warm-up, between 0 and 1.
+	//WarmUpExponential needs a Floor larger than 0.
+	Floor float64
+	Curve WarmUpCurve
+}
+
+//SetWarmUp makes the request limit ramp up from a floor when the host is used for the first time,
+//and again whenever the cooldown of HitRateLimit or HitBan ends. At least one request per period
+//is always allowed.
+//
+//	config.SetWarmUp(WarmUp{Duration: time.Minute, Floor: 0.1, Curve: WarmUpLinear})
+//The config above starts at 10% of the request limit and reaches the full limit after one minute.
+func (rl *RateLimitConfig) SetWarmUp(warmUp WarmUp) {
+	rl.warmUp = warmUp
+}
+
+//validate returns an error if the warm-up is not possible.
+func (w WarmUp) validate() error {
+	if w.Duration < 0 {
+		return fmt.Errorf("warm-up duration must not be negative, got %v", w.Duration)
+	}
+
+	if w.Floor < 0 || w.Floor > 1 {
+		return fmt.Errorf("warm-up floor must be between 0 and 1, got %v", w.Floor)
+	}
+
+	if w.Curve == WarmUpExponential && w.Duration > 0 && w.Floor == 0 {
+		return fmt.Errorf("an exponential warm-up needs a floor larger than 0")
+	}
+
+	if w.Curve != WarmUpLinear && w.Curve != WarmUpExponential {
+		return fmt.Errorf("unknown warm-up curve %v", w.Curve)
+	}
+
+	return nil
+}
+
+//share returns the share of the request limit that is allowed after elapsed milliseconds of the warm-up
+func (w WarmUp) share(elapsed int64) float64 {
+	duration := durationToMilliseconds(w.Duration)
+	if elapsed >= duration {
+		return 1
+	}
+
+	if elapsed < 0 {
+		elapsed = 0
+	}
+
+	progress := float64(elapsed) / float64(duration)
+	if w.Curve == WarmUpExponential {
+		return w.Floor * math.Pow(1/w.Floor, progress)
+	}
+
+	return w.Floor + (1-w.Floor)*progress
+}
+
+//warmUpStart returns when the current warm-up of the host began, which is the later of when
+//the status of the host was created and when its last cooldown ended.
+func (r *RequestsStatus) warmUpStart(config RateLimitConfig) int64 {
+	start := r.created
+
+	if cooldownEnd := r.lastErrorTime + config.waitAfterHitLimit; r.lastErrorTime > 0 && cooldownEnd > start {
+		start = cooldownEnd
+	}
+
+	if r.banUntil > start {
+		start = r.banUntil
+	}
+
+	return start
+}
+
+//warmedUp returns the config with the request limit that the warm-up allows at the given time.
+func (rl RateLimitConfig) warmedUp(status RequestsStatus, now int64) RateLimitConfig {
+	if rl.warmUp.Duration <= 0 || rl.requestLimit == 0 {
+		return rl
+	}
+
+	share := rl.warmUp.share(now - status.warmUpStart(rl))
+	if share >= 1 {
+		return rl
+	}
+
+	limit := int(float64(rl.requestLimit) * share)
+	if limit < 1 {
+		limit = 1
+	}
+
+	rl.requestLimit = limit
+	rl.setTimeBetweenRequests()
+	return rl
+}
@@ -0,0 +1,88 @@
+package limiter
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_WarmedUp(t *testing.T) {
+	now := getUnixTimeMilliseconds()
+
+	linear := NewRateLimitConfig("test_host_1", 100, 1, 100, 1, 5)
+	linear.SetWarmUp(WarmUp{Duration: 10 * time.Second, Floor: 0.2, Curve: WarmUpLinear})
+
+	exponential := NewRateLimitConfig("test_host_1", 100, 1, 100, 1, 5)
+	exponential.SetWarmUp(WarmUp{Duration: 10 * time.Second, Floor: 0.01, Curve: WarmUpExponential})
+
+	type TestWarmedUp struct {
+		config   RateLimitConfig
+		status   RequestsStatus
+		expected int
+	}
+
+	withCreated := func(createdAt int64) RequestsStatus {
+		status := newRequestsStatus(0, 0, 0, 0)
+		status.created = createdAt
+		return status
+	}
+
+	testCases := []TestWarmedUp{
+		{linear, withCreated(now), 20},
+		{linear, withCreated(now - 5000), 60},
+		{linear, withCreated(now - 10000), 100},
+		{linear, withCreated(0), 100}, //hosts from before warm-ups were added
+		//the warm-up starts over once the cooldown after the error has ended
+		{linear, newRequestsStatus(0, 0, 0, now-5000), 20},
+		{exponential, withCreated(now), 1},
+		{exponential, withCreated(now - 5000), 10},
+		{NewRateLimitConfig("test_host_1", 100, 1, 100, 1, 5), withCreated(now), 100},
+	}
+
+	for i := 0; i < len(testCases); i++ {
+		config := testCases[i].config.warmedUp(testCases[i].status, now)
+		if config.requestLimit != testCases[i].expected {
+			t.Errorf("Loop: %v. Expected %v, got: %v", i, testCases[i].expected, config.requestLimit)
+		}
+	}
+}
+
+func Test_InvalidWarmUp(t *testing.T) {
+	config := NewRateLimitConfig("warmUpHost", 10, 1, 10, 1, 0)
+	config.SetWarmUp(WarmUp{Duration: time.Minute, Curve: WarmUpExponential})
+
+	if err := config.Validate(); err == nil {
+		t.Errorf("Expected an error for an exponential warm-up without a floor")
+	}
+}
+
+func Test_CanMakeRequestDuringWarmUp(t *testing.T) {
+	deleteTestHosts(t, "warmUpHost")
+
+	config := newTestConfig("warmUpHost", 10)
+	config.SetWarmUp(WarmUp{Duration: time.Minute, Floor: 0.2})
+
+	limiter, err := NewLimiter(config, pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	granted := 0
+	for i := 0; i < 5; i++ {
+		canMake, _, err := limiter.CanMakeRequest(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if canMake {
+			granted++
+		}
+	}
+
+	if granted != 2 {
+		t.Errorf("Expected 2 requests to be granted at the start of the warm-up, got: %v", granted)
+	}
+
+	//a request larger than the warmed up limit waits instead of failing
+	if canMake, wait, err := limiter.CanMakeRequest(5); err != nil || canMake || wait <= 0 {
+		t.Errorf("Expected a heavy request to wait for the warm-up, got: %v, %v, %v", canMake, wait, err)
+	}
+}